@@ -0,0 +1,163 @@
+package access
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/opensourceways/server-common-lib/config"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	defaultTimeout    = 10 * time.Second
+	defaultMaxRetries = 3
+	defaultRetryDelay = time.Second
+)
+
+// Configuration is the config of access service.
+type Configuration struct {
+	Endpoints []Endpoint `json:"endpoints" required:"true"`
+
+	// MaxRetries is the max times to retry a failed forwarding. It is 3 if
+	// missing, and 0 disables the retries.
+	MaxRetries *int `json:"max_retries,omitempty"`
+
+	// RetryDelay is the delay before the first retry, which doubles after each retry.
+	RetryDelay string `json:"retry_delay,omitempty"`
+
+	retryDelay time.Duration
+}
+
+func (c *Configuration) Validate() error {
+	if c == nil {
+		return errors.New("missing config")
+	}
+
+	if len(c.Endpoints) == 0 {
+		return errors.New("missing endpoints")
+	}
+
+	names := sets.NewString()
+	for i := range c.Endpoints {
+		item := &c.Endpoints[i]
+
+		if err := item.validate(); err != nil {
+			return err
+		}
+
+		if names.Has(item.Name) {
+			return fmt.Errorf("duplicate endpoint: %s", item.Name)
+		}
+
+		names.Insert(item.Name)
+	}
+
+	if c.MaxRetries != nil && *c.MaxRetries < 0 {
+		return errors.New("max_retries can't be negative")
+	}
+
+	v, err := time.ParseDuration(c.RetryDelay)
+	if err != nil {
+		return fmt.Errorf("invalid retry_delay: %w", err)
+	}
+
+	c.retryDelay = v
+
+	return nil
+}
+
+func (c *Configuration) SetDefault() {
+	if c == nil {
+		return
+	}
+
+	if c.MaxRetries == nil {
+		v := defaultMaxRetries
+		c.MaxRetries = &v
+	}
+
+	if c.RetryDelay == "" {
+		c.RetryDelay = defaultRetryDelay.String()
+	}
+
+	for i := range c.Endpoints {
+		c.Endpoints[i].setDefault()
+	}
+}
+
+func (c *Configuration) maxRetries() int {
+	if c.MaxRetries == nil {
+		return defaultMaxRetries
+	}
+
+	return *c.MaxRetries
+}
+
+// endpointsFor returns the endpoints to which the event should be forwarded.
+func (c *Configuration) endpointsFor(eventType, org, repo string) []*Endpoint {
+	var r []*Endpoint
+
+	for i := range c.Endpoints {
+		if item := &c.Endpoints[i]; item.match(eventType, org, repo) {
+			r = append(r, item)
+		}
+	}
+
+	return r
+}
+
+// Endpoint is a robot to which the events are forwarded.
+type Endpoint struct {
+	config.RepoFilter
+
+	// Name is the unique name of endpoint.
+	Name string `json:"name" required:"true"`
+
+	// URL is the webhook endpoint of robot, such as http://robot-github-xxx:8888/github-hook.
+	URL string `json:"url" required:"true"`
+
+	// Events is the event types which the robot handles. All types are forwarded if it is empty.
+	Events []string `json:"events,omitempty"`
+
+	// Timeout is the timeout of each forwarding.
+	Timeout string `json:"timeout,omitempty"`
+
+	timeout time.Duration
+}
+
+func (e *Endpoint) setDefault() {
+	if e.Timeout == "" {
+		e.Timeout = defaultTimeout.String()
+	}
+}
+
+func (e *Endpoint) validate() error {
+	if e.Name == "" {
+		return errors.New("missing name of endpoint")
+	}
+
+	if _, err := url.ParseRequestURI(e.URL); err != nil {
+		return fmt.Errorf("invalid url of endpoint %s: %w", e.Name, err)
+	}
+
+	v, err := time.ParseDuration(e.Timeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout of endpoint %s: %w", e.Name, err)
+	}
+
+	e.timeout = v
+
+	return e.RepoFilter.Validate()
+}
+
+func (e *Endpoint) match(eventType, org, repo string) bool {
+	if len(e.Events) > 0 && !sets.NewString(e.Events...).Has(eventType) {
+		return false
+	}
+
+	applyOrgRepo, _ := e.CanApply(org, org+"/"+repo)
+
+	return applyOrgRepo
+}
@@ -0,0 +1,329 @@
+package access
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opensourceways/server-common-lib/config"
+	"github.com/sirupsen/logrus"
+
+	"github.com/opensourceways/robot-github-lib/client"
+)
+
+// userAgent is the User-Agent header which the robots trust.
+const userAgent = "Robot-Github-Access"
+
+// forwardedHeaders are the headers of webhook which are passed to the robots as is.
+var forwardedHeaders = []string{
+	"X-GitHub-Event",
+	"X-GitHub-Delivery",
+	"X-Hub-Signature",
+	"X-Hub-Signature-256",
+	"Content-Type",
+}
+
+// EndpointStatus is the status of forwarding to an endpoint.
+type EndpointStatus struct {
+	Name       string    `json:"name"`
+	Succeeded  uint64    `json:"succeeded"`
+	Failed     uint64    `json:"failed"`
+	LastStatus int       `json:"last_status,omitempty"`
+	LastError  string    `json:"last_error,omitempty"`
+	LastEvent  string    `json:"last_event,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Server validates the GitHub webhooks and forwards them to the robots.
+type Server struct {
+	agent          *config.ConfigAgent
+	tokenGenerator func() []byte
+	validateOpts   []client.ValidateOption
+	hc             *http.Client
+
+	// ctx is cancelled on shutdown to stop waiting for the retries.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Tracks running forwardings for graceful shutdown
+	wg sync.WaitGroup
+
+	mu     sync.Mutex
+	status map[string]*EndpointStatus
+}
+
+// NewServer returns a server which reads the config by the agent and validates
+// the webhooks with the hmac secret returned by tokenGenerator.
+func NewServer(
+	agent *config.ConfigAgent, tokenGenerator func() []byte, opts ...client.ValidateOption,
+) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Server{
+		agent:          agent,
+		tokenGenerator: tokenGenerator,
+		validateOpts:   opts,
+		hc:             &http.Client{},
+		ctx:            ctx,
+		cancel:         cancel,
+		status:         map[string]*EndpointStatus{},
+	}
+}
+
+// Wait waits for the running forwardings.
+func (s *Server) Wait() {
+	s.wg.Wait()
+}
+
+// Stop cancels the retries of the running forwardings. The requests which
+// are being sent are not cancelled, they end within the timeout of endpoint.
+func (s *Server) Stop() {
+	s.cancel()
+}
+
+func (s *Server) getConfig() *Configuration {
+	_, v := s.agent.GetConfig()
+	if c, ok := v.(*Configuration); ok {
+		return c
+	}
+
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	header := r.Header.Clone()
+
+	eventType, eventGUID, payload, ok, _ := client.ValidateWebhook(w, r, s.tokenGenerator, s.validateOpts...)
+	if !ok {
+		return
+	}
+
+	l := logrus.WithFields(logrus.Fields{
+		"event-type": eventType,
+		"event_id":   eventGUID,
+	})
+
+	cfg := s.getConfig()
+	if cfg == nil {
+		l.Error("can't load the config")
+		http.Error(w, "500 Internal Server Error: no config", http.StatusInternalServerError)
+
+		return
+	}
+
+	org, repo := parseOrgRepo(payload)
+	l = l.WithFields(logrus.Fields{"org": org, "repo": repo})
+
+	endpoints := cfg.endpointsFor(eventType, org, repo)
+	if len(endpoints) == 0 {
+		l.Debug("no endpoint for the event")
+
+		return
+	}
+
+	f := forwarding{
+		header:     header,
+		payload:    payload,
+		maxRetries: cfg.maxRetries(),
+		retryDelay: cfg.retryDelay,
+	}
+
+	for _, e := range endpoints {
+		e := *e
+
+		s.wg.Add(1)
+
+		go func() {
+			defer s.wg.Done()
+
+			s.forward(&e, &f, l.WithField("endpoint", e.Name))
+		}()
+	}
+}
+
+// forwarding is a webhook to be forwarded.
+type forwarding struct {
+	header     http.Header
+	payload    []byte
+	maxRetries int
+	retryDelay time.Duration
+}
+
+// forward sends the webhook to the endpoint and retries on failure.
+func (s *Server) forward(e *Endpoint, f *forwarding, l *logrus.Entry) {
+	delay := f.retryDelay
+
+	for n := 0; ; n++ {
+		code, retryAfter, err := s.send(e, f)
+		s.updateStatus(e.Name, f.header.Get("X-GitHub-Delivery"), code, err)
+
+		if err == nil {
+			l.WithField("status", code).Info("forwarded")
+
+			return
+		}
+
+		if n >= f.maxRetries || !isRetryable(code) {
+			l.WithError(err).Error("failed to forward")
+
+			return
+		}
+
+		wait := delay
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+
+		l.WithError(err).Warnf("retry forwarding after %s", wait)
+
+		select {
+		case <-time.After(wait):
+		case <-s.ctx.Done():
+			l.WithError(err).Error("failed to forward, stopped on shutdown")
+
+			return
+		}
+
+		delay *= 2
+	}
+}
+
+// send sends the webhook once. It returns the status code and the duration of
+// Retry-After header if the robot responds.
+func (s *Server) send(e *Endpoint, f *forwarding) (int, time.Duration, error) {
+	// the sending is not cancelled on shutdown, it ends within the timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(f.payload))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, k := range forwardedHeaders {
+		if v := f.header.Get(k); v != "" {
+			req.Header.Set(k, v)
+		}
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := s.hc.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(ioutil.Discard, resp.Body)
+
+		return resp.StatusCode, 0, nil
+	}
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+
+	return resp.StatusCode, parseRetryAfter(resp.Header.Get("Retry-After")), fmt.Errorf(
+		"status code: %d, body: %s", resp.StatusCode, strings.TrimSpace(string(body)),
+	)
+}
+
+// isRetryable tells whether the forwarding should be retried by the status code.
+// The code is 0 if the robot doesn't respond.
+func isRetryable(code int) bool {
+	return code == 0 || code == http.StatusTooManyRequests || code >= 500
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+
+	return 0
+}
+
+func (s *Server) updateStatus(name, guid string, code int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.status[name]
+	if !ok {
+		v = &EndpointStatus{Name: name}
+		s.status[name] = v
+	}
+
+	if err == nil {
+		v.Succeeded++
+		v.LastError = ""
+	} else {
+		v.Failed++
+		v.LastError = err.Error()
+	}
+
+	v.LastStatus = code
+	v.LastEvent = guid
+	v.UpdatedAt = time.Now()
+}
+
+// Status returns the status of each endpoint which has been forwarded to.
+func (s *Server) Status() []EndpointStatus {
+	s.mu.Lock()
+
+	r := make([]EndpointStatus, 0, len(s.status))
+	for _, v := range s.status {
+		r = append(r, *v)
+	}
+
+	s.mu.Unlock()
+
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].Name < r[j].Name
+	})
+
+	return r
+}
+
+// ServeStatus writes the status of endpoints as JSON.
+func (s *Server) ServeStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(s.Status())
+}
+
+// payloadOrgRepo is the part of payload to find the org and repo.
+type payloadOrgRepo struct {
+	Repo struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+
+	Org struct {
+		Login string `json:"login"`
+	} `json:"organization"`
+}
+
+// parseOrgRepo returns the org and repo of the event. The repo is empty
+// for the event of organization.
+func parseOrgRepo(payload []byte) (string, string) {
+	var v payloadOrgRepo
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return "", ""
+	}
+
+	if org := v.Repo.Owner.Login; org != "" {
+		return org, v.Repo.Name
+	}
+
+	return v.Org.Login, ""
+}
@@ -0,0 +1,185 @@
+package access
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type fakeResponse struct {
+	code       int
+	retryAfter string
+}
+
+func TestServerForward(t *testing.T) {
+	cases := []struct {
+		name       string
+		responses  []fakeResponse
+		maxRetries int
+		attempts   int
+		status     EndpointStatus
+		minElapsed time.Duration
+	}{
+		{
+			name:      "success",
+			responses: []fakeResponse{{code: http.StatusAccepted}},
+			attempts:  1,
+			status:    EndpointStatus{Succeeded: 1, LastStatus: http.StatusAccepted},
+		},
+		{
+			name: "retry on 5xx",
+			responses: []fakeResponse{
+				{code: http.StatusServiceUnavailable},
+				{code: http.StatusInternalServerError},
+				{code: http.StatusAccepted},
+			},
+			maxRetries: 3,
+			attempts:   3,
+			status:     EndpointStatus{Succeeded: 1, Failed: 2, LastStatus: http.StatusAccepted},
+		},
+		{
+			name: "retry on 429 after Retry-After",
+			responses: []fakeResponse{
+				{code: http.StatusTooManyRequests, retryAfter: "1"},
+				{code: http.StatusAccepted},
+			},
+			maxRetries: 3,
+			attempts:   2,
+			status:     EndpointStatus{Succeeded: 1, Failed: 1, LastStatus: http.StatusAccepted},
+			minElapsed: time.Second,
+		},
+		{
+			name:       "no retry on 4xx",
+			responses:  []fakeResponse{{code: http.StatusForbidden}},
+			maxRetries: 3,
+			attempts:   1,
+			status:     EndpointStatus{Failed: 1, LastStatus: http.StatusForbidden},
+		},
+		{
+			name: "retries exhausted",
+			responses: []fakeResponse{
+				{code: http.StatusBadGateway},
+				{code: http.StatusBadGateway},
+				{code: http.StatusAccepted},
+			},
+			maxRetries: 1,
+			attempts:   2,
+			status:     EndpointStatus{Failed: 2, LastStatus: http.StatusBadGateway},
+		},
+		{
+			name:      "retries disabled",
+			responses: []fakeResponse{{code: http.StatusBadGateway}, {code: http.StatusAccepted}},
+			attempts:  1,
+			status:    EndpointStatus{Failed: 1, LastStatus: http.StatusBadGateway},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var n int32
+
+			robot := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if ua := r.Header.Get("User-Agent"); ua != userAgent {
+					t.Errorf("User-Agent = %q, want %q", ua, userAgent)
+				}
+
+				resp := c.responses[int(atomic.AddInt32(&n, 1))-1]
+				if resp.retryAfter != "" {
+					w.Header().Set("Retry-After", resp.retryAfter)
+				}
+
+				w.WriteHeader(resp.code)
+			}))
+			defer robot.Close()
+
+			s := NewServer(nil, nil)
+			e := &Endpoint{Name: "robot", URL: robot.URL, timeout: time.Second}
+			f := &forwarding{
+				header:     http.Header{"X-Github-Delivery": []string{"guid"}},
+				payload:    []byte("{}"),
+				maxRetries: c.maxRetries,
+				retryDelay: time.Millisecond,
+			}
+
+			start := time.Now()
+			s.forward(e, f, logrus.NewEntry(logrus.New()))
+
+			if d := time.Since(start); d < c.minElapsed {
+				t.Errorf("forwarding takes %s, want at least %s", d, c.minElapsed)
+			}
+
+			if got := int(atomic.LoadInt32(&n)); got != c.attempts {
+				t.Errorf("sent %d times, want %d", got, c.attempts)
+			}
+
+			status := s.Status()
+			if len(status) != 1 {
+				t.Fatalf("Status() = %v, want one endpoint", status)
+			}
+
+			got := status[0]
+			if got.Name != "robot" || got.LastEvent != "guid" ||
+				got.Succeeded != c.status.Succeeded || got.Failed != c.status.Failed ||
+				got.LastStatus != c.status.LastStatus {
+				t.Errorf("Status() = %+v, want %+v", got, c.status)
+			}
+
+			if succeeded := c.status.LastStatus < 300; (got.LastError == "") != succeeded {
+				t.Errorf("LastError = %q", got.LastError)
+			}
+		})
+	}
+}
+
+func TestServerStopCancelsRetryWait(t *testing.T) {
+	robot := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer robot.Close()
+
+	s := NewServer(nil, nil)
+	e := &Endpoint{Name: "robot", URL: robot.URL, timeout: time.Second}
+	f := &forwarding{maxRetries: 3, retryDelay: time.Hour}
+
+	done := make(chan struct{})
+	go func() {
+		s.forward(e, f, logrus.NewEntry(logrus.New()))
+		close(done)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	s.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("forward doesn't stop waiting for the retry")
+	}
+}
+
+func TestConfigurationMaxRetries(t *testing.T) {
+	zero := 0
+
+	cases := []struct {
+		name string
+		cfg  Configuration
+		want int
+	}{
+		{name: "default", want: defaultMaxRetries},
+		{name: "disabled", cfg: Configuration{MaxRetries: &zero}, want: 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.cfg.SetDefault()
+
+			if got := c.cfg.maxRetries(); got != c.want {
+				t.Errorf("maxRetries() = %d, want %d", got, c.want)
+			}
+		})
+	}
+}
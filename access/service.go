@@ -0,0 +1,54 @@
+package access
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/opensourceways/server-common-lib/config"
+	"github.com/opensourceways/server-common-lib/interrupts"
+	"github.com/opensourceways/server-common-lib/options"
+	"github.com/opensourceways/server-common-lib/secret"
+	"github.com/sirupsen/logrus"
+
+	"github.com/opensourceways/robot-github-lib/client"
+)
+
+// Run runs the access service, which receives the webhooks at /github-hook and
+// reports the status of endpoints at /status.
+func Run(o options.ServiceOptions, hmacSecretFile string, opts ...client.ValidateOption) {
+	agent := config.NewConfigAgent(func() config.Config {
+		return &Configuration{}
+	})
+	if err := agent.Start(o.ConfigFile); err != nil {
+		logrus.WithError(err).Errorf("start config:%s", o.ConfigFile)
+		return
+	}
+
+	secretAgent := new(secret.Agent)
+	if err := secretAgent.Start([]string{hmacSecretFile}); err != nil {
+		logrus.WithError(err).Errorf("start hmac secret:%s", hmacSecretFile)
+		return
+	}
+
+	defer secretAgent.Stop()
+
+	s := NewServer(&agent, secretAgent.GetTokenGenerator(hmacSecretFile), opts...)
+
+	defer interrupts.WaitForGracefulShutdown()
+
+	interrupts.OnInterrupt(func() {
+		agent.Stop()
+		s.Stop()
+		s.Wait()
+	})
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	http.Handle("/github-hook", s)
+
+	http.HandleFunc("/status", s.ServeStatus)
+
+	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.Port)}
+
+	interrupts.ListenAndServe(httpServer, o.GracePeriod)
+}
@@ -15,17 +15,37 @@ func NewClient(getToken func() []byte) Client {
 		AccessToken: string(getToken()),
 	})
 	tc := oauth2.NewClient(context.Background(), ts)
+	tc.Transport = tracingTransport{next: instrumentedTransport{next: tc.Transport}}
 
-	return client{sdk.NewClient(tc)}
+	return client{c: sdk.NewClient(tc)}
 }
 
 type client struct {
 	c *sdk.Client
+
+	// ctx is the context of GitHub API calls. It is nil unless set by WithContext.
+	ctx context.Context
+}
+
+// WithContext returns a client whose GitHub API calls are done with the ctx,
+// so that they are cancelled when ctx is done.
+func (cl client) WithContext(ctx context.Context) Client {
+	cl.ctx = ctx
+
+	return cl
+}
+
+func (cl client) context() context.Context {
+	if cl.ctx != nil {
+		return cl.ctx
+	}
+
+	return context.Background()
 }
 
 func (cl client) AddPRLabel(pr PRInfo, label string) error {
 	_, _, err := cl.c.Issues.AddLabelsToIssue(
-		context.Background(),
+		cl.context(),
 		pr.Org, pr.Repo, pr.Number, []string{label},
 	)
 
@@ -34,7 +54,7 @@ func (cl client) AddPRLabel(pr PRInfo, label string) error {
 
 func (cl client) RemovePRLabel(pr PRInfo, label string) error {
 	r, err := cl.c.Issues.RemoveLabelForIssue(
-		context.Background(),
+		cl.context(),
 		pr.Org, pr.Repo, pr.Number, label,
 	)
 	if err != nil && r != nil && r.StatusCode == 404 {
@@ -49,7 +69,7 @@ func (cl client) CreatePRComment(pr PRInfo, comment string) error {
 		Body: sdk.String(comment),
 	}
 	_, _, err := cl.c.Issues.CreateComment(
-		context.Background(),
+		cl.context(),
 		pr.Org, pr.Repo, pr.Number, &ic,
 	)
 
@@ -57,7 +77,7 @@ func (cl client) CreatePRComment(pr PRInfo, comment string) error {
 }
 
 func (cl client) DeletePRComment(org, repo string, commentId int64) error {
-	_, err := cl.c.Issues.DeleteComment(context.Background(), org, repo, commentId)
+	_, err := cl.c.Issues.DeleteComment(cl.context(), org, repo, commentId)
 
 	return err
 }
@@ -69,7 +89,7 @@ func (cl client) GetPRComments(pr PRInfo) ([]*sdk.IssueComment, error) {
 	opt.Page = 1
 
 	for {
-		v, resp, err := cl.c.Issues.ListComments(context.Background(), pr.Org, pr.Repo, pr.Number, opt)
+		v, resp, err := cl.c.Issues.ListComments(cl.context(), pr.Org, pr.Repo, pr.Number, opt)
 		if err != nil {
 			return comments, err
 		}
@@ -109,7 +129,7 @@ func (cl client) GetPRCommits(pr PRInfo) ([]*sdk.RepositoryCommit, error) {
 		opt.Page = 1
 
 		for {
-			v, resp, err := cl.c.PullRequests.ListCommits(context.Background(), pr.Org, pr.Repo, pr.Number, nil)
+			v, resp, err := cl.c.PullRequests.ListCommits(cl.context(), pr.Org, pr.Repo, pr.Number, nil)
 			if err != nil {
 				return err
 			}
@@ -149,7 +169,7 @@ func (cl client) GetPRCommits(pr PRInfo) ([]*sdk.RepositoryCommit, error) {
 
 func (cl client) UpdatePR(pr PRInfo, request *sdk.PullRequest) (*sdk.PullRequest, error) {
 
-	pull, _, err := cl.c.PullRequests.Edit(context.Background(), pr.Org, pr.Repo, pr.Number, request)
+	pull, _, err := cl.c.PullRequests.Edit(cl.context(), pr.Org, pr.Repo, pr.Number, request)
 	if err != nil {
 		return nil, err
 	}
@@ -164,7 +184,7 @@ func (cl client) GetPullRequests(pr PRInfo) ([]*sdk.PullRequest, error) {
 		opt.Page = 1
 
 		for {
-			v, resp, err := cl.c.PullRequests.List(context.Background(), pr.Org, pr.Repo,
+			v, resp, err := cl.c.PullRequests.List(cl.context(), pr.Org, pr.Repo,
 				&sdk.PullRequestListOptions{ListOptions: *opt})
 			if err != nil {
 				return err
@@ -211,7 +231,7 @@ func (cl client) ListCollaborator(pr PRInfo) ([]*sdk.User, error) {
 		opt.Page = 1
 
 		for {
-			v, resp, err := cl.c.Repositories.ListCollaborators(context.Background(), pr.Org, pr.Repo,
+			v, resp, err := cl.c.Repositories.ListCollaborators(cl.context(), pr.Org, pr.Repo,
 				&sdk.ListCollaboratorsOptions{ListOptions: *opt})
 			if err != nil {
 				return err
@@ -251,7 +271,7 @@ func (cl client) ListCollaborator(pr PRInfo) ([]*sdk.User, error) {
 }
 
 func (cl client) IsCollaborator(pr PRInfo, login string) (bool, error) {
-	b, _, err := cl.c.Repositories.IsCollaborator(context.Background(), pr.Org, pr.Repo, login)
+	b, _, err := cl.c.Repositories.IsCollaborator(cl.context(), pr.Org, pr.Repo, login)
 	if err != nil {
 		return false, err
 	}
@@ -259,7 +279,7 @@ func (cl client) IsCollaborator(pr PRInfo, login string) (bool, error) {
 }
 
 func (cl client) RemoveRepoMember(pr PRInfo, login string) error {
-	_, err := cl.c.Repositories.RemoveCollaborator(context.Background(), pr.Org, pr.Repo, login)
+	_, err := cl.c.Repositories.RemoveCollaborator(cl.context(), pr.Org, pr.Repo, login)
 	if err != nil {
 		return err
 	}
@@ -268,7 +288,7 @@ func (cl client) RemoveRepoMember(pr PRInfo, login string) error {
 }
 
 func (cl client) AddRepoMember(pr PRInfo, login, permission string) error {
-	_, _, err := cl.c.Repositories.AddCollaborator(context.Background(), pr.Org, pr.Repo, login,
+	_, _, err := cl.c.Repositories.AddCollaborator(cl.context(), pr.Org, pr.Repo, login,
 		&sdk.RepositoryAddCollaboratorOptions{Permission: permission})
 	if err != nil {
 		return err
@@ -285,7 +305,7 @@ func (cl client) GetPullRequestChanges(pr PRInfo) ([]*sdk.CommitFile, error) {
 		opt.Page = 1
 
 		for {
-			v, resp, err := cl.c.PullRequests.ListFiles(context.Background(), pr.Org, pr.Repo, pr.Number, opt)
+			v, resp, err := cl.c.PullRequests.ListFiles(cl.context(), pr.Org, pr.Repo, pr.Number, opt)
 			if err != nil {
 				return err
 			}
@@ -324,7 +344,7 @@ func (cl client) GetPullRequestChanges(pr PRInfo) ([]*sdk.CommitFile, error) {
 }
 
 func (cl client) GetPRLabels(pr PRInfo) ([]string, error) {
-	pull, _, err := cl.c.PullRequests.Get(context.Background(), pr.Org, pr.Repo, pr.Number)
+	pull, _, err := cl.c.PullRequests.Get(cl.context(), pr.Org, pr.Repo, pr.Number)
 	if err != nil {
 		return nil, err
 	}
@@ -344,7 +364,7 @@ func (cl client) GetRepositoryLabels(pr PRInfo) ([]string, error) {
 		opt.Page = 1
 
 		for {
-			v, resp, err := cl.c.Issues.ListLabels(context.Background(), pr.Org, pr.Repo, opt)
+			v, resp, err := cl.c.Issues.ListLabels(cl.context(), pr.Org, pr.Repo, opt)
 			if err != nil {
 				return err
 			}
@@ -390,7 +410,7 @@ func (cl client) GetRepositoryLabels(pr PRInfo) ([]string, error) {
 }
 
 func (cl client) UpdatePRComment(pr PRInfo, commentID int64, ic *sdk.IssueComment) error {
-	_, _, err := cl.c.Issues.EditComment(context.Background(), pr.Org, pr.Repo, commentID, ic)
+	_, _, err := cl.c.Issues.EditComment(cl.context(), pr.Org, pr.Repo, commentID, ic)
 	if err != nil {
 		return err
 	}
@@ -400,7 +420,7 @@ func (cl client) UpdatePRComment(pr PRInfo, commentID int64, ic *sdk.IssueCommen
 
 func (cl client) ClosePR(pr PRInfo) error {
 	action := ActionClosed
-	_, _, err := cl.c.PullRequests.Edit(context.Background(), pr.Org, pr.Repo, pr.Number, &sdk.PullRequest{State: &action})
+	_, _, err := cl.c.PullRequests.Edit(cl.context(), pr.Org, pr.Repo, pr.Number, &sdk.PullRequest{State: &action})
 	if err != nil {
 		return err
 	}
@@ -410,7 +430,7 @@ func (cl client) ClosePR(pr PRInfo) error {
 
 func (cl client) ReopenPR(pr PRInfo) error {
 	action := "open"
-	_, _, err := cl.c.PullRequests.Edit(context.Background(), pr.Org, pr.Repo, pr.Number, &sdk.PullRequest{State: &action})
+	_, _, err := cl.c.PullRequests.Edit(cl.context(), pr.Org, pr.Repo, pr.Number, &sdk.PullRequest{State: &action})
 	if err != nil {
 		return err
 	}
@@ -419,7 +439,7 @@ func (cl client) ReopenPR(pr PRInfo) error {
 }
 
 func (cl client) AssignPR(pr PRInfo, logins []string) error {
-	_, _, err := cl.c.Issues.AddAssignees(context.Background(), pr.Org, pr.Repo, pr.Number, logins)
+	_, _, err := cl.c.Issues.AddAssignees(cl.context(), pr.Org, pr.Repo, pr.Number, logins)
 	if err != nil {
 		return err
 	}
@@ -428,7 +448,7 @@ func (cl client) AssignPR(pr PRInfo, logins []string) error {
 }
 
 func (cl client) UnAssignPR(pr PRInfo, logins []string) error {
-	_, _, err := cl.c.Issues.RemoveAssignees(context.Background(), pr.Org, pr.Repo, pr.Number, logins)
+	_, _, err := cl.c.Issues.RemoveAssignees(cl.context(), pr.Org, pr.Repo, pr.Number, logins)
 	if err != nil {
 		return err
 	}
@@ -438,7 +458,7 @@ func (cl client) UnAssignPR(pr PRInfo, logins []string) error {
 
 func (cl client) CloseIssue(pr PRInfo) error {
 	action := ActionClosed
-	_, _, err := cl.c.Issues.Edit(context.Background(), pr.Org, pr.Repo, pr.Number, &sdk.IssueRequest{State: &action})
+	_, _, err := cl.c.Issues.Edit(cl.context(), pr.Org, pr.Repo, pr.Number, &sdk.IssueRequest{State: &action})
 	if err != nil {
 		return err
 	}
@@ -448,7 +468,7 @@ func (cl client) CloseIssue(pr PRInfo) error {
 
 func (cl client) ReopenIssue(pr PRInfo) error {
 	action := "open"
-	_, _, err := cl.c.Issues.Edit(context.Background(), pr.Org, pr.Repo, pr.Number, &sdk.IssueRequest{State: &action})
+	_, _, err := cl.c.Issues.Edit(cl.context(), pr.Org, pr.Repo, pr.Number, &sdk.IssueRequest{State: &action})
 	if err != nil {
 		return err
 	}
@@ -457,7 +477,7 @@ func (cl client) ReopenIssue(pr PRInfo) error {
 }
 
 func (cl client) MergePR(pr PRInfo, commitMessage string, opt *sdk.PullRequestOptions) error {
-	_, _, err := cl.c.PullRequests.Merge(context.Background(), pr.Org, pr.Repo, pr.Number, commitMessage, opt)
+	_, _, err := cl.c.PullRequests.Merge(cl.context(), pr.Org, pr.Repo, pr.Number, commitMessage, opt)
 	if err != nil {
 		return err
 	}
@@ -473,7 +493,7 @@ func (cl client) GetRepos(org string) ([]*sdk.Repository, error) {
 		opt.PerPage = 100
 
 		for {
-			v, resp, err := cl.c.Repositories.ListByOrg(context.Background(), org, &sdk.RepositoryListByOrgOptions{ListOptions: *opt})
+			v, resp, err := cl.c.Repositories.ListByOrg(cl.context(), org, &sdk.RepositoryListByOrgOptions{ListOptions: *opt})
 			if err != nil {
 				return err
 			}
@@ -512,7 +532,7 @@ func (cl client) GetRepos(org string) ([]*sdk.Repository, error) {
 }
 
 func (cl client) GetRepo(org, repo string) (*sdk.Repository, error) {
-	r, _, err := cl.c.Repositories.Get(context.Background(), org, repo)
+	r, _, err := cl.c.Repositories.Get(cl.context(), org, repo)
 	if err != nil {
 		return nil, err
 	}
@@ -521,7 +541,7 @@ func (cl client) GetRepo(org, repo string) (*sdk.Repository, error) {
 }
 
 func (cl client) CreateRepo(org string, r *sdk.Repository) error {
-	_, _, err := cl.c.Repositories.Create(context.Background(), org, r)
+	_, _, err := cl.c.Repositories.Create(cl.context(), org, r)
 	if err != nil {
 		return err
 	}
@@ -530,7 +550,7 @@ func (cl client) CreateRepo(org string, r *sdk.Repository) error {
 }
 
 func (cl client) UpdateRepo(org, repo string, r *sdk.Repository) error {
-	_, _, err := cl.c.Repositories.Edit(context.Background(), org, repo, r)
+	_, _, err := cl.c.Repositories.Edit(cl.context(), org, repo, r)
 	if err != nil {
 		return err
 	}
@@ -539,7 +559,7 @@ func (cl client) UpdateRepo(org, repo string, r *sdk.Repository) error {
 }
 
 func (cl client) CreateRepoLabel(org, repo, label string) error {
-	_, _, err := cl.c.Issues.CreateLabel(context.Background(), org, repo, &sdk.Label{Name: &label})
+	_, _, err := cl.c.Issues.CreateLabel(cl.context(), org, repo, &sdk.Label{Name: &label})
 	if err != nil {
 		return err
 	}
@@ -554,7 +574,7 @@ func (cl client) GetRepoLabels(org, repo string) ([]string, error) {
 		opt.Page = 1
 
 		for {
-			v, resp, err := cl.c.Issues.ListLabels(context.Background(), org, repo, opt)
+			v, resp, err := cl.c.Issues.ListLabels(cl.context(), org, repo, opt)
 			if err != nil {
 				return err
 			}
@@ -601,7 +621,7 @@ func (cl client) GetRepoLabels(org, repo string) ([]string, error) {
 }
 
 func (cl client) AssignSingleIssue(is PRInfo, login string) error {
-	_, _, err := cl.c.Issues.AddAssignees(context.Background(), is.Org, is.Repo, is.Number, []string{login})
+	_, _, err := cl.c.Issues.AddAssignees(cl.context(), is.Org, is.Repo, is.Number, []string{login})
 	if err != nil {
 		return err
 	}
@@ -610,7 +630,7 @@ func (cl client) AssignSingleIssue(is PRInfo, login string) error {
 }
 
 func (cl client) UnAssignSingleIssue(is PRInfo, login string) error {
-	_, _, err := cl.c.Issues.RemoveAssignees(context.Background(), is.Org, is.Repo, is.Number, []string{login})
+	_, _, err := cl.c.Issues.RemoveAssignees(cl.context(), is.Org, is.Repo, is.Number, []string{login})
 	if err != nil {
 		return err
 	}
@@ -622,7 +642,7 @@ func (cl client) CreateIssueComment(is PRInfo, comment string) error {
 	ic := sdk.IssueComment{
 		Body: sdk.String(comment),
 	}
-	_, _, err := cl.c.Issues.CreateComment(context.Background(), is.Org, is.Repo, is.Number, &ic)
+	_, _, err := cl.c.Issues.CreateComment(cl.context(), is.Org, is.Repo, is.Number, &ic)
 	if err != nil {
 		return err
 	}
@@ -631,7 +651,7 @@ func (cl client) CreateIssueComment(is PRInfo, comment string) error {
 }
 
 func (cl client) UpdateIssueComment(is PRInfo, commentID int64, c *sdk.IssueComment) error {
-	_, _, err := cl.c.Issues.EditComment(context.Background(), is.Org, is.Repo, commentID, c)
+	_, _, err := cl.c.Issues.EditComment(cl.context(), is.Org, is.Repo, commentID, c)
 	if err != nil {
 		return err
 	}
@@ -646,7 +666,7 @@ func (cl client) ListIssueComments(is PRInfo) ([]*sdk.IssueComment, error) {
 	opt.Page = 1
 
 	for {
-		v, resp, err := cl.c.Issues.ListComments(context.Background(), is.Org, is.Repo, is.Number, opt)
+		v, resp, err := cl.c.Issues.ListComments(cl.context(), is.Org, is.Repo, is.Number, opt)
 		if err != nil {
 			return comments, err
 		}
@@ -679,7 +699,7 @@ func (cl client) ListIssueComments(is PRInfo) ([]*sdk.IssueComment, error) {
 }
 
 func (cl client) RemoveIssueLabel(is PRInfo, label string) error {
-	_, err := cl.c.Issues.RemoveLabelForIssue(context.Background(), is.Org, is.Repo, is.Number, label)
+	_, err := cl.c.Issues.RemoveLabelForIssue(cl.context(), is.Org, is.Repo, is.Number, label)
 	if err != nil {
 		return err
 	}
@@ -688,7 +708,7 @@ func (cl client) RemoveIssueLabel(is PRInfo, label string) error {
 }
 
 func (cl client) AddIssueLabel(is PRInfo, label []string) error {
-	_, _, err := cl.c.Issues.AddLabelsToIssue(context.Background(), is.Org, is.Repo, is.Number, label)
+	_, _, err := cl.c.Issues.AddLabelsToIssue(cl.context(), is.Org, is.Repo, is.Number, label)
 	if err != nil {
 		return err
 	}
@@ -703,7 +723,7 @@ func (cl client) GetIssueLabels(is PRInfo) ([]string, error) {
 		opt.Page = 1
 
 		for {
-			v, resp, err := cl.c.Issues.ListLabelsByIssue(context.Background(), is.Org, is.Repo, is.Number, opt)
+			v, resp, err := cl.c.Issues.ListLabelsByIssue(cl.context(), is.Org, is.Repo, is.Number, opt)
 			if err != nil {
 				return err
 			}
@@ -750,7 +770,7 @@ func (cl client) GetIssueLabels(is PRInfo) ([]string, error) {
 }
 
 func (cl client) UpdateIssue(is PRInfo, iss *sdk.IssueRequest) error {
-	_, _, err := cl.c.Issues.Edit(context.Background(), is.Org, is.Repo, is.Number, iss)
+	_, _, err := cl.c.Issues.Edit(cl.context(), is.Org, is.Repo, is.Number, iss)
 	if err != nil {
 		return err
 	}
@@ -759,7 +779,7 @@ func (cl client) UpdateIssue(is PRInfo, iss *sdk.IssueRequest) error {
 }
 
 func (cl client) GetSingleIssue(is PRInfo) (*sdk.Issue, error) {
-	issue, _, err := cl.c.Issues.Get(context.Background(), is.Org, is.Repo, is.Number)
+	issue, _, err := cl.c.Issues.Get(cl.context(), is.Org, is.Repo, is.Number)
 	if err != nil {
 		return nil, err
 	}
@@ -774,7 +794,7 @@ func (cl client) ListBranches(org, repo string) ([]*sdk.Branch, error) {
 		opt.Page = 1
 
 		for {
-			v, resp, err := cl.c.Repositories.ListBranches(context.Background(), org, repo,
+			v, resp, err := cl.c.Repositories.ListBranches(cl.context(), org, repo,
 				&sdk.BranchListOptions{ListOptions: *opt})
 			if err != nil {
 				return err
@@ -822,7 +842,7 @@ func (cl client) ListBranches(org, repo string) ([]*sdk.Branch, error) {
 }
 
 func (cl client) SetProtectionBranch(org, repo, branch string, pre *sdk.ProtectionRequest) error {
-	_, _, err := cl.c.Repositories.UpdateBranchProtection(context.Background(), org, repo, branch, pre)
+	_, _, err := cl.c.Repositories.UpdateBranchProtection(cl.context(), org, repo, branch, pre)
 	if err != nil {
 		return err
 	}
@@ -831,7 +851,7 @@ func (cl client) SetProtectionBranch(org, repo, branch string, pre *sdk.Protecti
 }
 
 func (cl client) RemoveProtectionBranch(org, repo, branch string) error {
-	_, err := cl.c.Repositories.RemoveBranchProtection(context.Background(), org, repo, branch)
+	_, err := cl.c.Repositories.RemoveBranchProtection(cl.context(), org, repo, branch)
 	if err != nil {
 		return err
 	}
@@ -840,7 +860,7 @@ func (cl client) RemoveProtectionBranch(org, repo, branch string) error {
 }
 
 func (cl client) GetDirectoryTree(org, repo, branch string, recursive bool) ([]*sdk.TreeEntry, error) {
-	trees, _, err := cl.c.Git.GetTree(context.Background(), org, repo, branch, recursive)
+	trees, _, err := cl.c.Git.GetTree(cl.context(), org, repo, branch, recursive)
 	if err != nil {
 		return nil, err
 	}
@@ -849,7 +869,7 @@ func (cl client) GetDirectoryTree(org, repo, branch string, recursive bool) ([]*
 }
 
 func (cl client) GetPathContent(org, repo, path, branch string) (*sdk.RepositoryContent, error) {
-	fc, _, _, err := cl.c.Repositories.GetContents(context.Background(), org, repo, path,
+	fc, _, _, err := cl.c.Repositories.GetContents(cl.context(), org, repo, path,
 		&sdk.RepositoryContentGetOptions{Ref: branch})
 	if err != nil {
 		return nil, err
@@ -859,7 +879,7 @@ func (cl client) GetPathContent(org, repo, path, branch string) (*sdk.Repository
 }
 
 func (cl client) CreateFile(org, repo, path, branch, commitMSG, sha string, content []byte) error {
-	_, _, err := cl.c.Repositories.CreateFile(context.Background(), org, repo, path,
+	_, _, err := cl.c.Repositories.CreateFile(cl.context(), org, repo, path,
 		&sdk.RepositoryContentFileOptions{Content: content, Message: &commitMSG, Branch: &branch, SHA: &sha})
 
 	if err != nil {
@@ -870,7 +890,7 @@ func (cl client) CreateFile(org, repo, path, branch, commitMSG, sha string, cont
 }
 
 func (cl client) GetUserPermissionOfRepo(org, repo, user string) (*sdk.RepositoryPermissionLevel, error) {
-	permission, _, err := cl.c.Repositories.GetPermissionLevel(context.Background(), org, repo, user)
+	permission, _, err := cl.c.Repositories.GetPermissionLevel(cl.context(), org, repo, user)
 	if err != nil {
 		return nil, err
 	}
@@ -879,7 +899,7 @@ func (cl client) GetUserPermissionOfRepo(org, repo, user string) (*sdk.Repositor
 }
 
 func (cl client) CreateIssue(org, repo string, request *sdk.IssueRequest) (*sdk.Issue, error) {
-	is, _, err := cl.c.Issues.Create(context.Background(), org, repo, request)
+	is, _, err := cl.c.Issues.Create(cl.context(), org, repo, request)
 	if err != nil {
 		return nil, err
 	}
@@ -888,7 +908,7 @@ func (cl client) CreateIssue(org, repo string, request *sdk.IssueRequest) (*sdk.
 }
 
 func (cl client) GetRef(org, repo, ref string) (*sdk.Reference, error) {
-	r, _, err := cl.c.Git.GetRef(context.Background(), org, repo, ref)
+	r, _, err := cl.c.Git.GetRef(cl.context(), org, repo, ref)
 	if err != nil {
 		return nil, err
 	}
@@ -897,7 +917,7 @@ func (cl client) GetRef(org, repo, ref string) (*sdk.Reference, error) {
 }
 
 func (cl client) CreateBranch(org, repo string, reference *sdk.Reference) error {
-	_, _, err := cl.c.Git.CreateRef(context.Background(), org, repo, reference)
+	_, _, err := cl.c.Git.CreateRef(cl.context(), org, repo, reference)
 	if err != nil {
 		return err
 	}
@@ -912,7 +932,7 @@ func (cl client) ListOperationLogs(pr PRInfo) ([]*sdk.Timeline, error) {
 		opt.Page = 1
 
 		for {
-			v, resp, err := cl.c.Issues.ListIssueTimeline(context.Background(), pr.Org, pr.Repo, pr.Number, opt)
+			v, resp, err := cl.c.Issues.ListIssueTimeline(cl.context(), pr.Org, pr.Repo, pr.Number, opt)
 			if err != nil {
 				return err
 			}
@@ -960,7 +980,7 @@ func (cl client) GetEnterprisesMember(org string) ([]*sdk.User, error) {
 		opt.Page = 1
 
 		for {
-			v, resp, err := cl.c.Organizations.ListMembers(context.Background(), org,
+			v, resp, err := cl.c.Organizations.ListMembers(cl.context(), org,
 				&sdk.ListMembersOptions{ListOptions: *opt})
 			if err != nil {
 				return err
@@ -1003,7 +1023,7 @@ func (cl client) GetEnterprisesMember(org string) ([]*sdk.User, error) {
 }
 
 func (cl client) GetSinglePR(org, repo string, number int) (*sdk.PullRequest, error) {
-	p, _, err := cl.c.PullRequests.Get(context.Background(), org, repo, number)
+	p, _, err := cl.c.PullRequests.Get(cl.context(), org, repo, number)
 	if err != nil {
 		return nil, err
 	}
@@ -1012,7 +1032,7 @@ func (cl client) GetSinglePR(org, repo string, number int) (*sdk.PullRequest, er
 }
 
 func (cl client) GetBot() (string, error) {
-	u, _, err := cl.c.Users.Get(context.Background(), "")
+	u, _, err := cl.c.Users.Get(cl.context(), "")
 	if err != nil {
 		return "", err
 	}
@@ -1025,7 +1045,7 @@ func (cl client) ListOrg() ([]string, error) {
 
 	opt := sdk.ListOptions{PerPage: 99, Page: 1}
 	for {
-		ls, _, err := cl.c.Organizations.List(context.Background(), "", &opt)
+		ls, _, err := cl.c.Organizations.List(cl.context(), "", &opt)
 		if err != nil {
 			return nil, err
 		}
@@ -1043,3 +1063,24 @@ func (cl client) ListOrg() ([]string, error) {
 
 	return r, nil
 }
+
+func (cl client) IsOrgMember(org, login string) (bool, error) {
+	b, _, err := cl.c.Organizations.IsMember(cl.context(), org, login)
+
+	return b, err
+}
+
+// IsTeamMember tells whether the user is an active member of the team
+// whose slug is team.
+func (cl client) IsTeamMember(org, team, login string) (bool, error) {
+	m, r, err := cl.c.Teams.GetTeamMembershipBySlug(cl.context(), org, team, login)
+	if err != nil {
+		if r != nil && r.StatusCode == 404 {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return m.GetState() == "active", nil
+}
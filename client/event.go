@@ -26,6 +26,15 @@ func GenIssuePRInfo(e interface{}) IssuePRInfo {
 	case *sdk.IssueCommentEvent:
 		return pullRequestCommentEvent{e}
 
+	case *sdk.IssuesEvent:
+		return issueEvent{e}
+
+	case *sdk.PullRequestReviewEvent:
+		return reviewEvent{e}
+
+	case *sdk.PullRequestReviewCommentEvent:
+		return reviewCommentEvent{e}
+
 	default:
 		return nil
 	}
@@ -82,3 +91,78 @@ func (e pullRequestCommentEvent) GetLabels() sets.String {
 func (e pullRequestCommentEvent) GetAuthor() string {
 	return e.e.GetIssue().GetUser().GetLogin()
 }
+
+type issueEvent struct {
+	e *sdk.IssuesEvent
+}
+
+func (e issueEvent) GetOrgRepo() (string, string) {
+	return GetOrgRepo(e.e.GetRepo())
+}
+
+func (e issueEvent) GetNumber() int {
+	return e.e.GetIssue().GetNumber()
+}
+
+func (e issueEvent) GetLabels() sets.String {
+	labels := sets.NewString()
+	for _, item := range e.e.GetIssue().Labels {
+		labels.Insert(item.GetName())
+	}
+
+	return labels
+}
+
+func (e issueEvent) GetAuthor() string {
+	return e.e.GetIssue().GetUser().GetLogin()
+}
+
+type reviewEvent struct {
+	e *sdk.PullRequestReviewEvent
+}
+
+func (e reviewEvent) GetOrgRepo() (string, string) {
+	return GetOrgRepo(e.e.GetRepo())
+}
+
+func (e reviewEvent) GetNumber() int {
+	return e.e.GetPullRequest().GetNumber()
+}
+
+func (e reviewEvent) GetLabels() sets.String {
+	labels := sets.NewString()
+	for _, item := range e.e.GetPullRequest().Labels {
+		labels.Insert(item.GetName())
+	}
+
+	return labels
+}
+
+func (e reviewEvent) GetAuthor() string {
+	return e.e.GetPullRequest().GetUser().GetLogin()
+}
+
+type reviewCommentEvent struct {
+	e *sdk.PullRequestReviewCommentEvent
+}
+
+func (e reviewCommentEvent) GetOrgRepo() (string, string) {
+	return GetOrgRepo(e.e.GetRepo())
+}
+
+func (e reviewCommentEvent) GetNumber() int {
+	return e.e.GetPullRequest().GetNumber()
+}
+
+func (e reviewCommentEvent) GetLabels() sets.String {
+	labels := sets.NewString()
+	for _, item := range e.e.GetPullRequest().Labels {
+		labels.Insert(item.GetName())
+	}
+
+	return labels
+}
+
+func (e reviewCommentEvent) GetAuthor() string {
+	return e.e.GetPullRequest().GetUser().GetLogin()
+}
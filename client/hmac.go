@@ -3,13 +3,16 @@ package client
 import (
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"hash"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/google/go-github/v36/github"
 	"github.com/sirupsen/logrus"
 	"sigs.k8s.io/yaml"
 )
@@ -23,31 +26,43 @@ type hmacSecret struct {
 // hmacsForRepo contains all hmac tokens configured for a repo, org or globally.
 type hmacsForRepo []hmacSecret
 
-type genericEvent struct {
-	Sender github.User       `json:"sender"`
-	Repo   github.Repository `json:"repository"`
+// payloadRepo is the part of payload to find the repository. The other parts
+// of payload are skipped without being decoded.
+type payloadRepo struct {
+	Repo struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
 }
 
+const (
+	signaturePrefixSHA1   = "sha1="
+	signaturePrefixSHA256 = "sha256="
+)
+
 // ValidatePayload ensures that the request payload signature matches the key.
-func ValidatePayload(payload []byte, sig string, tokenGenerator func() []byte) bool {
-	var event genericEvent
+// The signature is the value of X-Hub-Signature-256 which is in the form of
+// "sha256=...", or the one of X-Hub-Signature which is "sha1=..." only if
+// AllowSHA1Signature is set.
+//
+// The opts decide which tokens are still valid, see WithTokenMaxAge.
+func ValidatePayload(payload []byte, sig string, tokenGenerator func() []byte, opts ...ValidateOption) bool {
+	var event payloadRepo
 	if err := json.Unmarshal(payload, &event); err != nil {
 		logrus.WithError(err).Info("validatePayload couldn't unmarshal the github event payload")
 
 		return false
 	}
 
-	if !strings.HasPrefix(sig, "sha1=") {
-		return false
-	}
+	o := newValidateOptions(opts)
 
-	sig = sig[5:]
-	sb, err := hex.DecodeString(sig)
-	if err != nil {
+	newHash, sb, ok := parseSignature(sig, o.allowSHA1)
+	if !ok {
 		return false
 	}
 
-	hmacs, err := extractHmacs(event.Repo.GetFullName(), tokenGenerator)
+	repo := event.Repo.FullName
+
+	hmacs, err := extractHmacs(repo, tokenGenerator, &o, time.Now())
 	if err != nil {
 		logrus.WithError(err).Error("couldn't unmarshal the hmac secret")
 
@@ -55,12 +70,14 @@ func ValidatePayload(payload []byte, sig string, tokenGenerator func() []byte) b
 	}
 
 	// If we have a match with any valid hmac, we can validate successfully.
-	for _, key := range hmacs {
-		mac := hmac.New(sha1.New, key)
+	for _, token := range hmacs {
+		mac := hmac.New(newHash, token.value)
 		mac.Write(payload)
 		expected := mac.Sum(nil)
 
 		if hmac.Equal(sb, expected) {
+			logMatchedToken(repo, token)
+
 			return true
 		}
 	}
@@ -68,55 +85,226 @@ func ValidatePayload(payload []byte, sig string, tokenGenerator func() []byte) b
 	return false
 }
 
-// PayloadSignature returns the signature that matches the payload.
+// logMatchedToken logs the generation of token which matches the signature.
+// It is worth noticing when the sender still uses an old token.
+func logMatchedToken(repo string, token hmacToken) {
+	l := logrus.WithFields(logrus.Fields{
+		"repo":       repo,
+		"generation": token.generation,
+		"created_at": token.createdAt,
+	})
+
+	if token.generation > 0 {
+		l.Info("the signature matches an old hmac token")
+	} else {
+		l.Debug("the signature matches the newest hmac token")
+	}
+}
+
+// WithTokenMaxAge rejects the hmac tokens which were created more than maxAge ago,
+// except the newest one of the repo, org or global level. It only applies to the
+// tokens with created_at in the hierarchical secret file.
+func WithTokenMaxAge(maxAge time.Duration) ValidateOption {
+	return func(o *validateOptions) {
+		o.tokenMaxAge = maxAge
+	}
+}
+
+// WithRotationGrace keeps the previous token valid for the grace period after
+// the newest token is created, even if it is older than the max age.
+func WithRotationGrace(grace time.Duration) ValidateOption {
+	return func(o *validateOptions) {
+		o.rotationGrace = grace
+	}
+}
+
+// parseSignature returns the hash function and the decoded digest of the signature.
+// The SHA-1 signature is rejected unless allowSHA1 is true.
+func parseSignature(sig string, allowSHA1 bool) (func() hash.Hash, []byte, bool) {
+	var newHash func() hash.Hash
+
+	switch {
+	case strings.HasPrefix(sig, signaturePrefixSHA256):
+		newHash, sig = sha256.New, sig[len(signaturePrefixSHA256):]
+
+	case allowSHA1 && strings.HasPrefix(sig, signaturePrefixSHA1):
+		newHash, sig = sha1.New, sig[len(signaturePrefixSHA1):]
+
+	default:
+		return nil, nil, false
+	}
+
+	sb, err := hex.DecodeString(sig)
+	if err != nil {
+		return nil, nil, false
+	}
+
+	return newHash, sb, true
+}
+
+// PayloadSignature returns the SHA-1 signature that matches the payload,
+// which is the value of X-Hub-Signature header.
 func PayloadSignature(payload []byte, key []byte) string {
-	mac := hmac.New(sha1.New, key)
+	return signaturePrefixSHA1 + payloadDigest(sha1.New, payload, key)
+}
+
+// PayloadSignature256 returns the SHA-256 signature that matches the payload,
+// which is the value of X-Hub-Signature-256 header.
+func PayloadSignature256(payload []byte, key []byte) string {
+	return signaturePrefixSHA256 + payloadDigest(sha256.New, payload, key)
+}
+
+func payloadDigest(newHash func() hash.Hash, payload []byte, key []byte) string {
+	mac := hmac.New(newHash, key)
 	mac.Write(payload)
-	sum := mac.Sum(nil)
 
-	return "sha1=" + hex.EncodeToString(sum)
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
-// extractHmacs returns all *valid* HMAC tokens for given repository/organization.
-// It considers only the tokens at the most specific level configured for the given repo.
-// For example : if a token for repo is present and it doesn't match the repo, we will
-// not try to find a match with org level token. However if no token is present for repo,
-// we will try to match with org level.
-func extractHmacs(repo string, tokenGenerator func() []byte) ([][]byte, error) {
-	t := tokenGenerator()
+// hmacToken is a valid token. The generation is 0 for the newest token,
+// 1 for the previous one, and so on.
+type hmacToken struct {
+	value      []byte
+	generation int
+	createdAt  time.Time
+}
+
+// maxCachedSecrets is the max number of secret contents whose index is cached.
+const maxCachedSecrets = 8
+
+// secretIndex is the compiled content of a secret file.
+type secretIndex struct {
+	// single is the token if the content is in the single token format.
+	single []byte
+
+	// levels maps the repo, org or "*" to its tokens ordered from the newest to the oldest.
+	levels map[string][]hmacToken
+}
+
+// secretIndexCache caches the index of secret contents, so that the content
+// is parsed only when it changes.
+type secretIndexCache struct {
+	mu    sync.Mutex
+	items map[string]*secretIndex
+}
+
+var secretIndexes = secretIndexCache{items: map[string]*secretIndex{}}
+
+func (c *secretIndexCache) get(content []byte) *secretIndex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if v, ok := c.items[string(content)]; ok {
+		return v
+	}
+
+	if len(c.items) >= maxCachedSecrets {
+		c.items = map[string]*secretIndex{}
+	}
+
+	v := compileSecret(content)
+	c.items[string(content)] = v
+
+	return v
+}
+
+func compileSecret(content []byte) *secretIndex {
 	repoToTokenMap := map[string]hmacsForRepo{}
 
-	if err := yaml.Unmarshal(t, &repoToTokenMap); err != nil {
+	if err := yaml.Unmarshal(content, &repoToTokenMap); err != nil {
 		// To keep backward compatibility, we are going to assume that in case of error,
 		// whole file is a single line hmac token.
 		logrus.WithError(err).Trace("Couldn't unmarshal the hmac secret as hierarchical file. Parsing as single token format")
 
-		return [][]byte{t}, nil
+		single := make([]byte, len(content))
+		copy(single, content)
+
+		return &secretIndex{single: single}
+	}
+
+	levels := make(map[string][]hmacToken, len(repoToTokenMap))
+	for k, v := range repoToTokenMap {
+		levels[k] = compileTokens(v)
+	}
+
+	return &secretIndex{levels: levels}
+}
+
+// compileTokens orders the tokens from the newest to the oldest.
+func compileTokens(allTokens hmacsForRepo) []hmacToken {
+	sorted := make(hmacsForRepo, len(allTokens))
+	copy(sorted, allTokens)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	tokens := make([]hmacToken, len(sorted))
+	for i := range sorted {
+		tokens[i] = hmacToken{
+			value:      []byte(sorted[i].Value),
+			generation: i,
+			createdAt:  sorted[i].CreatedAt,
+		}
+	}
+
+	return tokens
+}
+
+// extractHmacs returns all *valid* HMAC tokens for given repository/organization.
+// It considers only the tokens at the most specific level configured for the given repo.
+// For example : if a token for repo is present and it doesn't match the repo, we will
+// not try to find a match with org level token. However if no token is present for repo,
+// we will try to match with org level.
+func extractHmacs(repo string, tokenGenerator func() []byte, o *validateOptions, now time.Time) ([]hmacToken, error) {
+	index := secretIndexes.get(tokenGenerator())
+
+	if index.single != nil {
+		return []hmacToken{{value: index.single}}, nil
 	}
 
 	orgName := strings.Split(repo, "/")[0]
 
-	if val, ok := repoToTokenMap[repo]; ok {
-		return extractTokens(val), nil
+	if val, ok := index.levels[repo]; ok {
+		return extractTokens(val, o, now), nil
 	}
 
-	if val, ok := repoToTokenMap[orgName]; ok {
-		return extractTokens(val), nil
+	if val, ok := index.levels[orgName]; ok {
+		return extractTokens(val, o, now), nil
 	}
 
-	if val, ok := repoToTokenMap["*"]; ok {
-		return extractTokens(val), nil
+	if val, ok := index.levels["*"]; ok {
+		return extractTokens(val, o, now), nil
 	}
 
 	return nil, errors.New("invalid content in secret file, global token doesn't exist")
 }
 
 // extractTokens return tokens for any given level of tree.
-func extractTokens(allTokens hmacsForRepo) [][]byte {
-	validTokens := make([][]byte, len(allTokens))
+// The tokens are ordered from the newest to the oldest.
+func extractTokens(allTokens []hmacToken, o *validateOptions, now time.Time) []hmacToken {
+	validTokens := make([]hmacToken, 0, len(allTokens))
 	for i := range allTokens {
-		validTokens[i] = []byte(allTokens[i].Value)
+		if i > 0 && !isTokenAlive(&allTokens[i], &allTokens[0], o, now) {
+			continue
+		}
+
+		validTokens = append(validTokens, allTokens[i])
 	}
 
 	return validTokens
 }
+
+// isTokenAlive tells whether the token is still valid.
+// The token without created_at never expires.
+func isTokenAlive(token, newest *hmacToken, o *validateOptions, now time.Time) bool {
+	if o.tokenMaxAge <= 0 || token.createdAt.IsZero() {
+		return true
+	}
+
+	if now.Sub(token.createdAt) <= o.tokenMaxAge {
+		return true
+	}
+
+	return token.generation == 1 && now.Sub(newest.createdAt) <= o.rotationGrace
+}
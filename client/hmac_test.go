@@ -0,0 +1,199 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExtractTokens(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tokens := compileTokens(hmacsForRepo{
+		{Value: "old", CreatedAt: now.Add(-72 * time.Hour)},
+		{Value: "newest", CreatedAt: now.Add(-time.Hour)},
+		{Value: "previous", CreatedAt: now.Add(-48 * time.Hour)},
+	})
+
+	stale := compileTokens(hmacsForRepo{
+		{Value: "newest", CreatedAt: now.Add(-48 * time.Hour)},
+		{Value: "previous", CreatedAt: now.Add(-72 * time.Hour)},
+	})
+
+	unversioned := compileTokens(hmacsForRepo{
+		{Value: "newest", CreatedAt: now.Add(-time.Hour)},
+		{Value: "legacy"},
+	})
+
+	cases := []struct {
+		name   string
+		tokens []hmacToken
+		opts   []ValidateOption
+		want   []string
+	}{
+		{
+			name:   "no max age",
+			tokens: tokens,
+			want:   []string{"newest", "previous", "old"},
+		},
+		{
+			name:   "all alive",
+			tokens: tokens,
+			opts:   []ValidateOption{WithTokenMaxAge(100 * time.Hour)},
+			want:   []string{"newest", "previous", "old"},
+		},
+		{
+			name:   "expired",
+			tokens: tokens,
+			opts:   []ValidateOption{WithTokenMaxAge(24 * time.Hour)},
+			want:   []string{"newest"},
+		},
+		{
+			name:   "previous kept in grace window",
+			tokens: tokens,
+			opts:   []ValidateOption{WithTokenMaxAge(24 * time.Hour), WithRotationGrace(2 * time.Hour)},
+			want:   []string{"newest", "previous"},
+		},
+		{
+			name:   "previous expired after grace window",
+			tokens: tokens,
+			opts:   []ValidateOption{WithTokenMaxAge(24 * time.Hour), WithRotationGrace(30 * time.Minute)},
+			want:   []string{"newest"},
+		},
+		{
+			name:   "grace window applies only to previous",
+			tokens: tokens,
+			opts:   []ValidateOption{WithTokenMaxAge(50 * time.Hour), WithRotationGrace(2 * time.Hour)},
+			want:   []string{"newest", "previous"},
+		},
+		{
+			name:   "newest never expires",
+			tokens: stale,
+			opts:   []ValidateOption{WithTokenMaxAge(24 * time.Hour)},
+			want:   []string{"newest"},
+		},
+		{
+			name:   "token without created_at never expires",
+			tokens: unversioned,
+			opts:   []ValidateOption{WithTokenMaxAge(time.Minute)},
+			want:   []string{"newest", "legacy"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := newValidateOptions(c.opts)
+
+			var got []string
+			for _, v := range extractTokens(c.tokens, &o, now) {
+				got = append(got, string(v.value))
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("extractTokens() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSecretIndexCache(t *testing.T) {
+	c := secretIndexCache{items: map[string]*secretIndex{}}
+
+	hierarchical := []byte("'*':\n- value: global\nowner:\n- value: org\n")
+
+	first := c.get(hierarchical)
+	if first.single != nil || len(first.levels) != 2 {
+		t.Fatalf("get() = %+v, want the hierarchical index", first)
+	}
+
+	if got := c.get([]byte(string(hierarchical))); got != first {
+		t.Error("get() compiles the same content again")
+	}
+
+	single := c.get([]byte("token"))
+	if string(single.single) != "token" || single.levels != nil {
+		t.Errorf("get() = %+v, want the single token index", single)
+	}
+
+	for i := 0; i < maxCachedSecrets; i++ {
+		c.get([]byte{byte('a' + i)})
+	}
+
+	if len(c.items) > maxCachedSecrets {
+		t.Errorf("cache holds %d items, want at most %d", len(c.items), maxCachedSecrets)
+	}
+
+	if got := c.get(hierarchical); got == first || !reflect.DeepEqual(got, first) {
+		t.Error("get() doesn't compile the evicted content again")
+	}
+}
+
+func TestValidatePayloadSignature(t *testing.T) {
+	payload := []byte(`{"repository":{"full_name":"owner/repo"},"action":"opened"}`)
+	secret := []byte("'*':\n- value: global\nowner/repo:\n- value: repo\n")
+	tokenGenerator := func() []byte { return secret }
+
+	cases := []struct {
+		name    string
+		sig     string
+		payload []byte
+		opts    []ValidateOption
+		want    bool
+	}{
+		{
+			name: "sha256",
+			sig:  PayloadSignature256(payload, []byte("repo")),
+			want: true,
+		},
+		{
+			name: "sha1 allowed",
+			sig:  PayloadSignature(payload, []byte("repo")),
+			opts: []ValidateOption{AllowSHA1Signature()},
+			want: true,
+		},
+		{
+			name: "sha1 not allowed",
+			sig:  PayloadSignature(payload, []byte("repo")),
+		},
+		{
+			name: "sha256 when sha1 allowed",
+			sig:  PayloadSignature256(payload, []byte("repo")),
+			opts: []ValidateOption{AllowSHA1Signature()},
+			want: true,
+		},
+		{
+			name: "token of less specific level",
+			sig:  PayloadSignature256(payload, []byte("global")),
+		},
+		{
+			name:    "tampered payload",
+			sig:     PayloadSignature256(payload, []byte("repo")),
+			payload: []byte(`{"repository":{"full_name":"owner/repo"},"action":"closed"}`),
+		},
+		{
+			name: "sha1 digest with sha256 prefix",
+			sig:  signaturePrefixSHA256 + PayloadSignature(payload, []byte("repo"))[len(signaturePrefixSHA1):],
+		},
+		{
+			name: "unknown prefix",
+			sig:  "md5=" + PayloadSignature256(payload, []byte("repo"))[len(signaturePrefixSHA256):],
+		},
+		{
+			name: "invalid hex",
+			sig:  signaturePrefixSHA256 + "zz",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := c.payload
+			if p == nil {
+				p = payload
+			}
+
+			if got := ValidatePayload(p, c.sig, tokenGenerator, c.opts...); got != c.want {
+				t.Errorf("ValidatePayload() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
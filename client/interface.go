@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 
 	sdk "github.com/google/go-github/v36/github"
@@ -72,4 +73,9 @@ type Client interface {
 	GetSinglePR(org, repo string, number int) (*sdk.PullRequest, error)
 	GetBot() (string, error)
 	ListOrg() ([]string, error)
+	IsOrgMember(org, login string) (bool, error)
+	IsTeamMember(org, team, login string) (bool, error)
+
+	// WithContext returns a client whose GitHub API calls are cancelled when ctx is done.
+	WithContext(ctx context.Context) Client
 }
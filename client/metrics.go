@@ -0,0 +1,88 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "robot_github",
+	Name:      "api_requests_total",
+	Help:      "Number of GitHub API requests by http method, route and status code.",
+}, []string{"method", "route", "status"})
+
+// RegisterMetrics registers the metrics of GitHub API calls made by the clients to reg.
+func RegisterMetrics(reg prometheus.Registerer) error {
+	return reg.Register(apiRequests)
+}
+
+// instrumentedTransport counts the requests sent through it.
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+
+	apiRequests.WithLabelValues(req.Method, apiRoute(req.URL.EscapedPath()), status).Inc()
+
+	return resp, err
+}
+
+// routeParams are the names of path parameters following the segment.
+// The parameter named "*" takes all the remaining segments.
+var routeParams = map[string][]string{
+	"repos":         {":owner", ":repo"},
+	"orgs":          {":org"},
+	"users":         {":user"},
+	"teams":         {":team"},
+	"members":       {":user"},
+	"memberships":   {":user"},
+	"collaborators": {":user"},
+	"labels":        {":label"},
+	"branches":      {":branch"},
+	"trees":         {":sha"},
+	"contents":      {"*"},
+	"ref":           {"*"},
+	"refs":          {"*"},
+}
+
+// apiRoute replaces the parameters in the path of GitHub API with their names,
+// for example /repos/:owner/:repo/issues/:number/comments, so that the number
+// of label values is bounded.
+func apiRoute(path string) string {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+
+	var params []string
+	for i, seg := range segs {
+		if len(params) > 0 {
+			if params[0] == "*" {
+				segs = append(segs[:i], ":path")
+				break
+			}
+
+			segs[i] = params[0]
+			params = params[1:]
+
+			continue
+		}
+
+		if _, err := strconv.ParseInt(seg, 10, 64); err == nil {
+			segs[i] = ":number"
+
+			continue
+		}
+
+		params = routeParams[seg]
+	}
+
+	return "/" + strings.Join(segs, "/")
+}
@@ -3,21 +3,57 @@ package client
 import (
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// ValidateOption customizes how ValidateWebhook validates the webhook.
+type ValidateOption func(*validateOptions)
+
+type validateOptions struct {
+	allowSHA1     bool
+	tokenMaxAge   time.Duration
+	rotationGrace time.Duration
+}
+
+func newValidateOptions(opts []ValidateOption) validateOptions {
+	o := validateOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// AllowSHA1Signature makes ValidateWebhook fall back to the legacy SHA-1
+// signature of X-Hub-Signature header when X-Hub-Signature-256 is missing,
+// and makes ValidatePayload accept the SHA-1 signature.
+func AllowSHA1Signature() ValidateOption {
+	return func(o *validateOptions) {
+		o.allowSHA1 = true
+	}
+}
+
 // ValidateWebhook ensures that the provided request conforms to the
 // format of a GitHub webhook and the payload can be validated with
 // the provided hmac secret. It returns the event type, the event guid,
 // the payload of the request, whether the webhook is valid or not,
 // and finally the resultant HTTP status code
+//
+// The signature is read from X-Hub-Signature-256 header. The one of
+// X-Hub-Signature is used only if AllowSHA1Signature is set.
 func ValidateWebhook(
 	w http.ResponseWriter,
 	r *http.Request,
 	tokenGenerator func() []byte,
+	opts ...ValidateOption,
 ) (eType string, guid string, payload []byte, ok bool, status int) {
 	defer r.Body.Close()
+
+	o := newValidateOptions(opts)
+
 	// Header checks: It must be a POST with an event type and a signature.
 	if r.Method != http.MethodPost {
 		status = http.StatusMethodNotAllowed
@@ -40,10 +76,20 @@ func ValidateWebhook(
 		return
 	}
 
-	sig := r.Header.Get("X-Hub-Signature")
+	sig := r.Header.Get("X-Hub-Signature-256")
+	if sig == "" && o.allowSHA1 {
+		sig = r.Header.Get("X-Hub-Signature")
+	}
+
 	if sig == "" {
 		status = http.StatusForbidden
-		responseHTTPError(w, status, "403 Forbidden: Missing X-Hub-Signature")
+		responseHTTPError(w, status, "403 Forbidden: Missing X-Hub-Signature-256")
+		return
+	}
+
+	if !o.allowSHA1 && !strings.HasPrefix(sig, signaturePrefixSHA256) {
+		status = http.StatusForbidden
+		responseHTTPError(w, status, "403 Forbidden: Invalid signature")
 		return
 	}
 
@@ -65,9 +111,9 @@ func ValidateWebhook(
 	}
 
 	// Validate the payload with our HMAC secret.
-	if !ValidatePayload(payload, sig, tokenGenerator) {
+	if !ValidatePayload(payload, sig, tokenGenerator, opts...) {
 		status = http.StatusForbidden
-		responseHTTPError(w, status, "403 Forbidden: Invalid X-Hub-Signature")
+		responseHTTPError(w, status, "403 Forbidden: Invalid signature")
 
 		return
 	}
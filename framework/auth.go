@@ -0,0 +1,64 @@
+package framework
+
+import (
+	"net/http"
+
+	"github.com/opensourceways/robot-github-lib/client"
+)
+
+// AuthMode decides how the framework authenticates the webhooks.
+type AuthMode int
+
+const (
+	// AuthTrustedRelay accepts the webhooks forwarded by the trusted access
+	// service, which is recognized by the User-Agent header. It is the default.
+	AuthTrustedRelay AuthMode = iota
+
+	// AuthHMAC validates the signature of webhooks with the HMAC secret,
+	// so that the robot can receive the webhooks from GitHub directly.
+	AuthHMAC
+
+	// AuthBoth requires the webhooks to be forwarded by the trusted access
+	// service and have the valid signature.
+	AuthBoth
+)
+
+const trustedRelayUserAgent = "Robot-Github-Access"
+
+// authenticator authenticates the webhook and returns its content.
+type authenticator struct {
+	mode AuthMode
+
+	// hmacToken returns the HMAC secret. It is required unless the mode is AuthTrustedRelay.
+	hmacToken func() []byte
+
+	validateOpts []client.ValidateOption
+}
+
+func (a *authenticator) parseRequest(w http.ResponseWriter, r *http.Request) (
+	eventType string, uuid string, payload []byte, ok bool,
+) {
+	switch a.mode {
+	case AuthHMAC:
+		eventType, uuid, payload, ok, _ = client.ValidateWebhook(w, r, a.hmacToken, a.validateOpts...)
+
+	case AuthBoth:
+		if !isTrustedRelay(r) {
+			r.Body.Close()
+			http.Error(w, "400 Bad Request: unknown User-Agent Header", http.StatusBadRequest)
+
+			return
+		}
+
+		eventType, uuid, payload, ok, _ = client.ValidateWebhook(w, r, a.hmacToken, a.validateOpts...)
+
+	default:
+		eventType, uuid, payload, ok = parseRequest(w, r)
+	}
+
+	return
+}
+
+func isTrustedRelay(r *http.Request) bool {
+	return r.Header.Get("User-Agent") == trustedRelayUserAgent
+}
@@ -0,0 +1,280 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v36/github"
+	"github.com/opensourceways/server-common-lib/config"
+	"github.com/opensourceways/server-common-lib/utils"
+	"github.com/sirupsen/logrus"
+
+	"github.com/opensourceways/robot-github-lib/client"
+)
+
+const logFieldCommand = "command"
+
+var commandLineRe = regexp.MustCompile(`^/([a-zA-Z][\w-]*)(?:\s+(.*))?$`)
+
+// CommandContext is where a command can be used.
+type CommandContext int
+
+const (
+	CommandOnIssue CommandContext = 1 << iota
+	CommandOnPR
+
+	CommandOnAll = CommandOnIssue | CommandOnPR
+)
+
+// CommandArgs is the schema of arguments of a command. The zero value
+// accepts no argument.
+type CommandArgs struct {
+	Min int
+
+	// Max is the max number of arguments. There is no limit if it is negative.
+	Max int
+
+	// Pattern, if set, must match each argument.
+	Pattern *regexp.Regexp
+}
+
+func (a *CommandArgs) validate(args []string) error {
+	if n := len(args); n < a.Min || (a.Max >= 0 && n > a.Max) {
+		return fmt.Errorf("got %d arguments", n)
+	}
+
+	if a.Pattern != nil {
+		for _, v := range args {
+			if !a.Pattern.MatchString(v) {
+				return fmt.Errorf("invalid argument: %s", v)
+			}
+		}
+	}
+
+	return nil
+}
+
+// CommandCall is a command found in a comment.
+type CommandCall struct {
+	Name  string
+	Args  []string
+	IsPR  bool
+	Event *github.IssueCommentEvent
+}
+
+type CommandHandler func(c *CommandCall, cfg config.Config, log *logrus.Entry) error
+
+// Command is a slash command in comment, such as /assign @x.
+type Command struct {
+	// Name is the name of command without the slash. It is case insensitive.
+	Name string
+
+	Description string
+
+	// Usage shows how to use the command, such as "/assign [@user ...]".
+	Usage string
+
+	Args CommandArgs
+
+	// Contexts is where the command can be used. It is CommandOnAll if zero.
+	Contexts CommandContext
+
+	// Permission is who can use the command. It requires a PermissionChecker
+	// set by WithPermissionChecker unless it is empty.
+	Permission Permission
+
+	// Enabled tells whether the command is enabled for the repository by the
+	// config of robot. The command is enabled everywhere if it is nil.
+	Enabled func(cfg config.Config, org, repo string) bool
+
+	Handler CommandHandler
+}
+
+func (c *Command) isEnabled(cfg config.Config, org, repo string) bool {
+	return c.Enabled == nil || c.Enabled(cfg, org, repo)
+}
+
+func (c *Command) canUseOn(isPR bool) bool {
+	ctx := c.Contexts
+	if ctx == 0 {
+		ctx = CommandOnAll
+	}
+
+	if isPR {
+		return ctx&CommandOnPR != 0
+	}
+
+	return ctx&CommandOnIssue != 0
+}
+
+// CommandRouter dispatches the commands in the created comments to the
+// handlers. Its Handle can be registered as an IssueCommentHandler.
+type CommandRouter struct {
+	commands map[string]*Command
+
+	// names keeps the order of registration.
+	names []string
+
+	checker *PermissionChecker
+	helpCli CommentClient
+}
+
+// CommandRouterOption configures the CommandRouter.
+type CommandRouterOption func(*CommandRouter)
+
+// WithPermissionChecker sets the checker of permissions of commands.
+func WithPermissionChecker(pc *PermissionChecker) CommandRouterOption {
+	return func(r *CommandRouter) {
+		r.checker = pc
+	}
+}
+
+func NewCommandRouter(opts ...CommandRouterOption) *CommandRouter {
+	r := &CommandRouter{commands: map[string]*Command{}}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.helpCli != nil {
+		_ = r.Register(r.helpCommand())
+	}
+
+	return r
+}
+
+// Register registers the commands. It fails if the name is duplicate or the
+// permission can't be checked.
+func (r *CommandRouter) Register(cmds ...Command) error {
+	for i := range cmds {
+		c := cmds[i]
+
+		if c.Name == "" || c.Handler == nil {
+			return errors.New("missing name or handler of command")
+		}
+
+		if err := c.Permission.validate(); err != nil {
+			return err
+		}
+
+		if !c.Permission.isEmpty() && r.checker == nil {
+			return fmt.Errorf("missing permission checker for command: %s", c.Name)
+		}
+
+		c.Name = strings.ToLower(c.Name)
+		if _, ok := r.commands[c.Name]; ok {
+			return fmt.Errorf("duplicate command: %s", c.Name)
+		}
+
+		r.commands[c.Name] = &c
+		r.names = append(r.names, c.Name)
+	}
+
+	return nil
+}
+
+// Handle runs the handlers of commands in the comment one by one and returns
+// the errors of all the handlers. The unknown commands, the commands used in
+// wrong context and the ones with invalid arguments are skipped, so are the
+// disabled ones and the ones which the commenter is not allowed to use.
+func (r *CommandRouter) Handle(e *github.IssueCommentEvent, cfg config.Config, log *logrus.Entry) error {
+	if !client.IsCommentCreated(e) {
+		return nil
+	}
+
+	isPR := client.IsCommentOnPullRequest(e)
+	org, repo := e.GetRepo().GetOwner().GetLogin(), e.GetRepo().GetName()
+	mErr := utils.NewMultiErrors()
+
+	for _, call := range parseCommands(e.GetComment().GetBody()) {
+		c, ok := r.commands[call.Name]
+		if !ok || !c.isEnabled(cfg, org, repo) {
+			continue
+		}
+
+		l := log.WithField(logFieldCommand, c.Name)
+
+		if !c.canUseOn(isPR) {
+			l.Debug("the command can't be used here")
+
+			continue
+		}
+
+		if err := c.Args.validate(call.Args); err != nil {
+			l.WithError(err).Infof("invalid command, usage: %s", c.Usage)
+
+			continue
+		}
+
+		if !c.Permission.isEmpty() {
+			b, err := r.checker.Check(c.Permission, e, "use the command `/"+c.Name+"`")
+			if err != nil {
+				mErr.AddError(fmt.Errorf("/%s: %w", c.Name, err))
+			}
+
+			if !b {
+				continue
+			}
+		}
+
+		call.IsPR = isPR
+		call.Event = e
+
+		if err := c.Handler(&call, cfg, l); err != nil {
+			mErr.AddError(fmt.Errorf("/%s: %w", c.Name, err))
+		}
+	}
+
+	return mErr.Err()
+}
+
+// parseCommands returns the commands in the comment, one per line. The lines
+// in code blocks and quotes are ignored.
+func parseCommands(body string) []CommandCall {
+	var (
+		r     []CommandCall
+		fence string
+	)
+
+	for _, raw := range strings.Split(body, "\n") {
+		line := strings.TrimSpace(raw)
+
+		if fence != "" {
+			if strings.HasPrefix(line, fence) {
+				fence = ""
+			}
+
+			continue
+		}
+
+		if isIndentedCode(raw) {
+			continue
+		}
+
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			fence = line[:3]
+
+			continue
+		}
+
+		if strings.HasPrefix(line, ">") {
+			continue
+		}
+
+		if m := commandLineRe.FindStringSubmatch(line); m != nil {
+			r = append(r, CommandCall{
+				Name: strings.ToLower(m[1]),
+				Args: strings.Fields(m[2]),
+			})
+		}
+	}
+
+	return r
+}
+
+// isIndentedCode tells whether the line is in an indented code block,
+// which is indented by a tab or at least 4 spaces.
+func isIndentedCode(line string) bool {
+	return strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "    ")
+}
@@ -0,0 +1,74 @@
+package framework
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommands(t *testing.T) {
+	cases := []struct {
+		name string
+		body string
+		want []CommandCall
+	}{
+		{
+			name: "one command",
+			body: "/lgtm",
+			want: []CommandCall{{Name: "lgtm", Args: []string{}}},
+		},
+		{
+			name: "command with args",
+			body: "/Assign  @alice @bob ",
+			want: []CommandCall{{Name: "assign", Args: []string{"@alice", "@bob"}}},
+		},
+		{
+			name: "one command per line",
+			body: "some text\r\n/lgtm\r\n  /approve\nnot /merge",
+			want: []CommandCall{{Name: "lgtm", Args: []string{}}, {Name: "approve", Args: []string{}}},
+		},
+		{
+			name: "backquote fence",
+			body: "```\n/merge\n```\n/lgtm",
+			want: []CommandCall{{Name: "lgtm", Args: []string{}}},
+		},
+		{
+			name: "tilde fence with language",
+			body: "~~~sh\n/merge\n```\n/close\n~~~\n/lgtm",
+			want: []CommandCall{{Name: "lgtm", Args: []string{}}},
+		},
+		{
+			name: "indented fence",
+			body: "  ```\n/merge\n  ```",
+		},
+		{
+			name: "unclosed fence",
+			body: "```\n/merge",
+		},
+		{
+			name: "quote",
+			body: "> /merge\n>/close\n/lgtm",
+			want: []CommandCall{{Name: "lgtm", Args: []string{}}},
+		},
+		{
+			name: "indented code by spaces",
+			body: "    /merge\n   /lgtm",
+			want: []CommandCall{{Name: "lgtm", Args: []string{}}},
+		},
+		{
+			name: "indented code by tab",
+			body: "\t/merge",
+		},
+		{
+			name: "not a command",
+			body: "/ merge\n/1st\nhttp://a/b",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := parseCommands(c.body); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("parseCommands(%q) = %#v, want %#v", c.body, got, c.want)
+			}
+		})
+	}
+}
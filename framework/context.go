@@ -0,0 +1,33 @@
+package framework
+
+import (
+	"context"
+
+	"github.com/opensourceways/server-common-lib/config"
+	"github.com/sirupsen/logrus"
+)
+
+// WithContext adapts a handler which needs a context to the function contract
+// of the typed handlers, so that it can be registered by HandlerRegister.
+// For example:
+//
+//	f.RegisterIssueHandler(framework.WithContext(bot.handleIssueEvent))
+//
+// The context is cancelled when the process is interrupted or the handler times out.
+// Pass it to the client.Client by its WithContext method to cancel the GitHub API calls.
+func WithContext[E any](
+	fn func(ctx context.Context, e E, cfg config.Config, log *logrus.Entry) error,
+) func(E, config.Config, *logrus.Entry) error {
+	return func(e E, cfg config.Config, log *logrus.Entry) error {
+		return fn(ContextOf(log), e, cfg, log)
+	}
+}
+
+// ContextOf returns the context carried by the log entry passed to a handler.
+func ContextOf(log *logrus.Entry) context.Context {
+	if log != nil && log.Context != nil {
+		return log.Context
+	}
+
+	return context.Background()
+}
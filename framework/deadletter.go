@@ -0,0 +1,190 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/opensourceways/robot-github-lib/client"
+)
+
+// replayHandlerHeader is the header of a replayed dead letter, which limits the
+// handling to the handler that failed.
+const replayHandlerHeader = "X-Robot-Replay-Handler"
+
+// handlerID identifies a handler among all the robots. The index is counted in
+// the generic handlers or the typed handlers of the event type.
+func handlerID(robot string, generic bool, index int) string {
+	kind := "typed"
+	if generic {
+		kind = "generic"
+	}
+
+	return robot + "/" + kind + "/" + strconv.Itoa(index)
+}
+
+// DeadLetter is an event whose handler kept failing after all the retries.
+type DeadLetter struct {
+	EventType string          `json:"event_type"`
+	GUID      string          `json:"guid"`
+	Payload   json.RawMessage `json:"payload"`
+	Robot     string          `json:"robot,omitempty"`
+	Generic   bool            `json:"generic,omitempty"`
+	Handler   int             `json:"handler"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error"`
+	FailedAt  time.Time       `json:"failed_at"`
+
+	path string
+}
+
+// deadLetterBox stores the dead letters as files in a dir. A nil box does nothing.
+type deadLetterBox struct {
+	dir string
+}
+
+func newDeadLetterBox(dir string) (*deadLetterBox, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &deadLetterBox{dir: dir}, nil
+}
+
+func (b *deadLetterBox) save(dl *DeadLetter) (string, error) {
+	if b == nil {
+		return "", nil
+	}
+
+	v, err := json.MarshalIndent(dl, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf(
+		"%020d-%s-%d%s",
+		dl.FailedAt.UnixNano(),
+		unsafeFileNameChars.ReplaceAllString(dl.GUID, "_"),
+		dl.Handler,
+		journalFileSuffix,
+	)
+
+	return writeFileAtomically(filepath.Join(b.dir, name), v)
+}
+
+// LoadDeadLetters returns the dead letters stored in dir in the order of failure.
+func LoadDeadLetters(dir string) ([]DeadLetter, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+journalFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+
+	r := make([]DeadLetter, 0, len(paths))
+
+	for _, p := range paths {
+		v, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		item := DeadLetter{path: p}
+		if err := json.Unmarshal(v, &item); err != nil {
+			return nil, fmt.Errorf("invalid dead letter file %s: %w", p, err)
+		}
+
+		r = append(r, item)
+	}
+
+	return r, nil
+}
+
+// ReplayOption customizes the replay of dead letter.
+type ReplayOption func(*replayOptions)
+
+type replayOptions struct {
+	secret      []byte
+	allHandlers bool
+}
+
+// WithReplaySecret signs the replayed event with the secret, which is required
+// if the robot authenticates the webhooks by AuthHMAC or AuthBoth. The secret
+// must be one of the tokens accepted by the robot.
+func WithReplaySecret(secret []byte) ReplayOption {
+	return func(o *replayOptions) {
+		o.secret = secret
+	}
+}
+
+// WithReplayAllHandlers runs all the handlers of the event again, rather than
+// only the failed one.
+func WithReplayAllHandlers() ReplayOption {
+	return func(o *replayOptions) {
+		o.allHandlers = true
+	}
+}
+
+// Replay sends the event to the webhook endpoint of robot, such as
+// http://localhost:8888/github-hook. Only the failed handler is run again by
+// default. A new delivery ID derived from the original one is used, so that
+// the event will not be dropped as a duplicate delivery.
+func (dl *DeadLetter) Replay(endpoint string, opts ...ReplayOption) error {
+	var o replayOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(dl.Payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", trustedRelayUserAgent)
+	req.Header.Set("X-GitHub-Event", dl.EventType)
+	req.Header.Set(
+		"X-GitHub-Delivery",
+		dl.GUID+"-replay-"+strconv.FormatInt(time.Now().UnixNano(), 10),
+	)
+
+	if len(o.secret) > 0 {
+		req.Header.Set("X-Hub-Signature-256", client.PayloadSignature256(dl.Payload, o.secret))
+	}
+
+	if !o.allHandlers {
+		req.Header.Set(replayHandlerHeader, handlerID(dl.Robot, dl.Generic, dl.Handler))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	// The robot responds 202 only if it accepts the event.
+	if resp.StatusCode != http.StatusAccepted {
+		v, _ := ioutil.ReadAll(resp.Body)
+
+		return fmt.Errorf("replay failed, status code: %d, body: %s", resp.StatusCode, v)
+	}
+
+	return nil
+}
+
+// Remove deletes the dead letter file, for example, after it is replayed.
+func (dl *DeadLetter) Remove() error {
+	if dl.path == "" {
+		return nil
+	}
+
+	return os.Remove(dl.path)
+}
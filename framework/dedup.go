@@ -0,0 +1,167 @@
+package framework
+
+import (
+	"container/list"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDedupTTL  = time.Hour
+	defaultDedupSize = 10000
+)
+
+// deliveryCache records the delivery IDs seen recently, so that the duplicate
+// deliveries can be dropped. A nil cache records nothing.
+type deliveryCache struct {
+	ttl     time.Duration
+	maxSize int
+
+	// file is the path to persist the records. It is optional.
+	file string
+
+	mu sync.Mutex
+	// items is ordered by the time when the delivery is seen,
+	// which is also the order of expiration.
+	items *list.List
+	index map[string]*list.Element
+}
+
+type deliveryRecord struct {
+	GUID   string    `json:"guid"`
+	SeenAt time.Time `json:"seen_at"`
+}
+
+func newDeliveryCache(ttl time.Duration, maxSize int, file string) *deliveryCache {
+	return &deliveryCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		file:    file,
+		items:   list.New(),
+		index:   map[string]*list.Element{},
+	}
+}
+
+// add records the guid and tells whether it is seen for the first time.
+func (c *deliveryCache) add(guid string) bool {
+	if c == nil {
+		return true
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire(now)
+
+	if _, ok := c.index[guid]; ok {
+		return false
+	}
+
+	c.push(deliveryRecord{GUID: guid, SeenAt: now})
+
+	return true
+}
+
+// remove forgets the guid, so that the redelivery will be accepted.
+// It is used when the delivery is not accepted.
+func (c *deliveryCache) remove(guid string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.index[guid]; ok {
+		c.items.Remove(e)
+		delete(c.index, guid)
+	}
+}
+
+func (c *deliveryCache) push(r deliveryRecord) {
+	c.index[r.GUID] = c.items.PushBack(r)
+
+	for c.items.Len() > c.maxSize {
+		c.removeFront()
+	}
+}
+
+func (c *deliveryCache) expire(now time.Time) {
+	for e := c.items.Front(); e != nil; e = c.items.Front() {
+		if now.Sub(e.Value.(deliveryRecord).SeenAt) < c.ttl {
+			return
+		}
+
+		c.removeFront()
+	}
+}
+
+func (c *deliveryCache) removeFront() {
+	e := c.items.Front()
+	c.items.Remove(e)
+	delete(c.index, e.Value.(deliveryRecord).GUID)
+}
+
+// load reads the records persisted before. It does nothing if the file doesn't exist.
+func (c *deliveryCache) load() error {
+	if c == nil || c.file == "" {
+		return nil
+	}
+
+	v, err := ioutil.ReadFile(c.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	var records []deliveryRecord
+	if err := json.Unmarshal(v, &records); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, r := range records {
+		if _, ok := c.index[r.GUID]; !ok {
+			c.push(r)
+		}
+	}
+
+	c.expire(time.Now())
+
+	return nil
+}
+
+// save persists the records which are not expired.
+func (c *deliveryCache) save() error {
+	if c == nil || c.file == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.expire(time.Now())
+
+	records := make([]deliveryRecord, 0, c.items.Len())
+	for e := c.items.Front(); e != nil; e = e.Next() {
+		records = append(records, e.Value.(deliveryRecord))
+	}
+	c.mu.Unlock()
+
+	v, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	_, err = writeFileAtomically(c.file, v)
+
+	return err
+}
@@ -1,13 +1,23 @@
 package framework
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/go-github/v36/github"
 	"github.com/opensourceways/server-common-lib/config"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/opensourceways/robot-github-lib/client"
 )
@@ -17,57 +27,398 @@ const (
 	logFieldRepo   = "repo"
 	logFieldURL    = "url"
 	logFieldAction = "action"
+
+	logFieldHandler   = "handler"
+	logFieldEventType = "event-type"
+	logFieldEventGUID = "event_id"
+	logFieldRobot     = "robot"
+
+	// retryAfterSeconds is the value of Retry-After header when the queue is full.
+	retryAfterSeconds = "10"
+)
+
+var (
+	errQueueFull = errors.New("the event queue is full")
+
+	// errDuplicate is returned if the delivery has been received.
+	errDuplicate = errors.New("duplicate delivery")
+
+	// errInvalidPayload is returned if the payload can't be decoded.
+	errInvalidPayload = errors.New("invalid payload")
+
+	// errUnknownEvent is returned if no handler can handle the event type.
+	errUnknownEvent = errors.New("unknown event type")
 )
 
 type dispatcher struct {
+	// name is the name of robot, which is empty if only one robot is run.
+	name string
+
 	agent *config.ConfigAgent
 
 	h handlers
 
+	middlewares []Middleware
+
+	journal *journal
+
+	retry      retryPolicy
+	deadLetter *deadLetterBox
+
+	// ctx is the parent context of handlers, which is cancelled on shutdown.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+
+	// deliveries records the recent deliveries to drop the duplicate ones.
+	deliveries *deliveryCache
+
+	// queue holds the events waiting for the workers.
+	queue   chan task
+	workers int
+
+	// pending holds the tasks waiting for the queued or running task of same key.
+	// A key exists in it only when there is a queued or running task of that key.
+	pending    map[string][]task
+	pendingNum int
+	pendingMu  sync.Mutex
+
 	// Tracks running handlers for graceful shutdown
 	wg sync.WaitGroup
+
+	metrics *metrics
+	tracer  trace.Tracer
+}
+
+// task is the handling of an event which is run by a worker.
+// The tasks of same key are processed one by one in the order of receipt.
+type task struct {
+	dv     delivery
+	key    string
+	log    *logrus.Entry
+	handle func()
+
+	// entry is the path of journal entry of the event.
+	entry string
+}
+
+func newDispatcher(name string, agent *config.ConfigAgent, h handlers, o *runOptions) *dispatcher {
+	d := &dispatcher{
+		name:        name,
+		agent:       agent,
+		h:           h,
+		middlewares: o.middlewares,
+		journal:     o.journal,
+		retry:       o.retry,
+		deadLetter:  o.deadLetter,
+		deliveries:  o.deliveries,
+		queue:       make(chan task, o.queueSize),
+		workers:     o.workers,
+		pending:     map[string][]task{},
+		timeout:     o.handlerTimeout,
+		metrics:     o.metrics,
+		tracer:      o.tracerProvider.Tracer(tracerName),
+	}
+
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+
+	for i := 0; i < d.workers; i++ {
+		go d.work()
+	}
+
+	return d
 }
 
 func (d *dispatcher) Wait() {
 	d.wg.Wait() // Handle remaining requests
 }
 
-func (d *dispatcher) Dispatch(eventType string, payload []byte, l *logrus.Entry) error {
-	hook, err := github.ParseWebHook(eventType, payload)
+// Stop cancels the context of the running handlers. The events which are
+// not handled yet will be skipped if the journal is enabled, so that they
+// can be replayed at next startup, otherwise they are lost.
+func (d *dispatcher) Stop() {
+	d.cancel()
+}
+
+// WaitTimeout waits for the remaining events at most timeout, and tells
+// whether all of them are handled.
+func (d *dispatcher) WaitTimeout(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		d.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// QueueDepth returns the number of events waiting for the workers.
+func (d *dispatcher) QueueDepth() int {
+	d.pendingMu.Lock()
+	n := d.pendingNum
+	d.pendingMu.Unlock()
+
+	return len(d.queue) + n
+}
+
+// delivery is a webhook delivery.
+type delivery struct {
+	eventType string
+	guid      string
+	payload   []byte
+
+	// span is the span of receipt, which is the parent of handler spans.
+	span trace.SpanContext
+
+	// handler is the ID of the only handler to run, which is set when a dead
+	// letter is replayed. All the handlers are run if it is empty.
+	handler string
+}
+
+// receive dispatches the delivery unless it has been received.
+func (d *dispatcher) receive(dv delivery, p parsedPayload, l *logrus.Entry) error {
+	if dv.handler != "" && !strings.HasPrefix(dv.handler, d.name+"/") {
+		return errUnknownEvent
+	}
+
+	if !d.deliveries.add(dv.guid) {
+		d.metrics.duplicateDropped(d.name)
+
+		return errDuplicate
+	}
+
+	err := d.Dispatch(dv, p, l)
+	if err != nil {
+		d.deliveries.remove(dv.guid)
+	}
+
+	return err
+}
+
+// Dispatch queues the delivery whose payload is parsed as p.
+func (d *dispatcher) Dispatch(dv delivery, p parsedPayload, l *logrus.Entry) error {
+	t, err := d.newTask(dv, p, l)
 	if err != nil {
 		return err
 	}
 
+	t.entry, err = d.journal.save(&journalEntry{
+		EventType:  dv.eventType,
+		GUID:       dv.guid,
+		Payload:    dv.payload,
+		ReceivedAt: time.Now(),
+		Handler:    dv.handler,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = d.enqueue(t); err != nil {
+		if err1 := d.journal.remove(t.entry); err1 != nil {
+			l.WithError(err1).Error("remove journal entry")
+		}
+	}
+
+	return err
+}
+
+// Replay dispatches the events persisted in journal, which were received but
+// not handled before the last exit. It blocks until all the events are queued.
+func (d *dispatcher) Replay() error {
+	records, err := d.journal.load()
+	if err != nil {
+		return err
+	}
+
+	for i := range records {
+		item := &records[i]
+
+		var t task
+
+		dv := delivery{
+			eventType: item.EventType,
+			guid:      item.GUID,
+			payload:   item.Payload,
+			handler:   item.Handler,
+		}
+		l := d.robotLog(logrus.WithFields(logrus.Fields{
+			logFieldEventType: dv.eventType,
+			logFieldEventGUID: dv.guid,
+			"journal":         item.path,
+		}))
+
+		p, err := parsePayload(dv)
+		if err == nil {
+			t, err = d.newTask(dv, p, l)
+		}
+
+		if err != nil {
+			l.WithError(err).Error("can't replay the event")
+
+			if err := d.journal.remove(item.path); err != nil {
+				l.WithError(err).Error("remove journal entry")
+			}
+
+			continue
+		}
+
+		t.entry = item.path
+
+		d.deliveries.add(dv.guid)
+
+		d.put(t, true)
+
+		l.Info("replay the event")
+	}
+
+	return nil
+}
+
+// parsedPayload is the payload parsed by github.ParseWebHook. It is shared by
+// the robots, so the handlers must not modify the event.
+type parsedPayload struct {
+	hook interface{}
+	err  error
+}
+
+// parsePayload parses the payload of delivery. It returns errInvalidPayload if
+// the payload is not JSON. The error of parsing it as the event type is kept in
+// parsedPayload, because it can still be handled by the generic handlers.
+func parsePayload(dv delivery) (parsedPayload, error) {
+	if !json.Valid(dv.payload) {
+		return parsedPayload{}, errInvalidPayload
+	}
+
+	hook, err := github.ParseWebHook(dv.eventType, dv.payload)
+
+	return parsedPayload{hook: hook, err: err}, nil
+}
+
+// newTask returns the task to handle the event parsed as p. It returns
+// errInvalidPayload if the payload can't be decoded as the event type and
+// errUnknownEvent if the event type is not supported.
+func (d *dispatcher) newTask(dv delivery, p parsedPayload, l *logrus.Entry) (task, error) {
+	generics := d.h.genericHandlersOf(dv.eventType)
+
+	hook := p.hook
+	if p.err != nil {
+		if len(generics) == 0 {
+			return task{}, parseError(p.err)
+		}
+
+		l.WithError(p.err).Debug("only the generic handlers will handle the event")
+
+		hook = nil
+	}
+
+	handle := d.typedHandle(hook, dv, l)
+
+	if len(generics) > 0 {
+		typed := handle
+		e := &GenericEvent{
+			Type:    dv.eventType,
+			GUID:    dv.guid,
+			Payload: dv.payload,
+			Parsed:  hook,
+		}
+
+		handle = func() {
+			if typed != nil {
+				typed()
+			}
+
+			d.handleGenericEvent(e, generics, dv, l)
+		}
+	}
+
+	if handle == nil {
+		return task{}, errUnknownEvent
+	}
+
+	return task{dv: dv, key: eventKey(hook), log: l, handle: handle}, nil
+}
+
+// parseError classifies the error of github.ParseWebHook. The payload is
+// valid JSON, so it fails either on an unknown type or a mismatched field.
+func parseError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("%w: %v", errInvalidPayload, err)
+	}
+
+	return fmt.Errorf("%w: %v", errUnknownEvent, err)
+}
+
+// typedHandle returns the handling of the event by the handlers registered for
+// its type. It returns nil if the type of event is not supported.
+func (d *dispatcher) typedHandle(hook interface{}, dv delivery, l *logrus.Entry) (handle func()) {
+	// n is the number of handlers for the type of event.
+	n := 0
+
 	switch hook := hook.(type) {
 	case *github.IssuesEvent:
-		d.wg.Add(1)
-		go d.handleIssueEvent(hook, l)
+		n = len(d.h.issueHandlers)
+		handle = func() { d.handleIssueEvent(hook, dv, l) }
 	case *github.PullRequestEvent:
-		d.wg.Add(1)
-		go d.handlePullRequestEvent(hook, l)
+		n = len(d.h.pullRequestHandlers)
+		handle = func() { d.handlePullRequestEvent(hook, dv, l) }
 	case *github.PushEvent:
-		d.wg.Add(1)
-		go d.handlePushEvent(hook, l)
+		n = len(d.h.pushEventHandlers)
+		handle = func() { d.handlePushEvent(hook, dv, l) }
 	case *github.IssueCommentEvent:
-		d.wg.Add(1)
-		go d.handleIssueCommentEvent(hook, l)
+		n = len(d.h.issueCommentHandlers)
+		handle = func() { d.handleIssueCommentEvent(hook, dv, l) }
 	case *github.PullRequestReviewEvent:
-		d.wg.Add(1)
-		go d.handleReviewEvent(hook, l)
+		n = len(d.h.reviewEventHandlers)
+		handle = func() { d.handleReviewEvent(hook, dv, l) }
 	case *github.PullRequestReviewCommentEvent:
-		d.wg.Add(1)
-		go d.handleReviewCommentEvent(hook, l)
+		n = len(d.h.reviewCommentEventHandlers)
+		handle = func() { d.handleReviewCommentEvent(hook, dv, l) }
 	case *github.StatusEvent:
-		d.wg.Add(1)
-		go d.handleStatusEvent(hook, l)
+		n = len(d.h.statusEventHandlers)
+		handle = func() { d.handleStatusEvent(hook, dv, l) }
 	case *github.CommitCommentEvent:
-		d.wg.Add(1)
-		go d.handleCommitCommentEvent(hook, l)
-	default:
-		l.Debug("Ignoring unknown event type")
+		n = len(d.h.commitCommentEventHandlers)
+		handle = func() { d.handleCommitCommentEvent(hook, dv, l) }
+	case *github.CheckRunEvent:
+		n = len(d.h.checkRunEventHandlers)
+		handle = func() { d.handleCheckRunEvent(hook, dv, l) }
+	case *github.CheckSuiteEvent:
+		n = len(d.h.checkSuiteEventHandlers)
+		handle = func() { d.handleCheckSuiteEvent(hook, dv, l) }
+	case *github.WorkflowRunEvent:
+		n = len(d.h.workflowRunEventHandlers)
+		handle = func() { d.handleWorkflowRunEvent(hook, dv, l) }
+	case *github.CreateEvent:
+		n = len(d.h.createEventHandlers)
+		handle = func() { d.handleCreateEvent(hook, dv, l) }
+	case *github.DeleteEvent:
+		n = len(d.h.deleteEventHandlers)
+		handle = func() { d.handleDeleteEvent(hook, dv, l) }
+	case *github.ForkEvent:
+		n = len(d.h.forkEventHandlers)
+		handle = func() { d.handleForkEvent(hook, dv, l) }
+	case *github.RepositoryEvent:
+		n = len(d.h.repositoryEventHandlers)
+		handle = func() { d.handleRepositoryEvent(hook, dv, l) }
+	case *github.MemberEvent:
+		n = len(d.h.memberEventHandlers)
+		handle = func() { d.handleMemberEvent(hook, dv, l) }
+	case *github.LabelEvent:
+		n = len(d.h.labelEventHandlers)
+		handle = func() { d.handleLabelEvent(hook, dv, l) }
 	}
 
-	return nil
+	if n == 0 {
+		return nil
+	}
+
+	return
 }
 
 func (d *dispatcher) getConfig() config.Config {
@@ -76,38 +427,35 @@ func (d *dispatcher) getConfig() config.Config {
 	return c
 }
 
-func (d *dispatcher) handleIssueEvent(e *github.IssuesEvent, l *logrus.Entry) {
-	defer d.wg.Done()
-
+func (d *dispatcher) handleIssueEvent(e *github.IssuesEvent, dv delivery, l *logrus.Entry) {
 	l = l.WithFields(logrus.Fields{
 		logFieldURL:    e.GetIssue().GetHTMLURL(),
 		logFieldAction: e.GetAction(),
 	})
 
-	if err := d.h.issueHandlers(e, d.getConfig(), l); err != nil {
-		l.WithError(err).Error()
-	} else {
-		l.Info()
+	cfg := d.getConfig()
+	for i, h := range d.h.issueHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
 	}
 }
 
-func (d *dispatcher) handlePullRequestEvent(e *github.PullRequestEvent, l *logrus.Entry) {
-	defer d.wg.Done()
-
+func (d *dispatcher) handlePullRequestEvent(e *github.PullRequestEvent, dv delivery, l *logrus.Entry) {
 	l = l.WithFields(logrus.Fields{
 		logFieldURL:    e.GetPullRequest().GetHTMLURL(),
 		logFieldAction: e.GetAction(),
 	})
 
-	if err := d.h.pullRequestHandler(e, d.getConfig(), l); err != nil {
-		l.WithError(err).Error()
-	} else {
-		l.Info()
+	cfg := d.getConfig()
+	for i, h := range d.h.pullRequestHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
 	}
 }
 
-func (d *dispatcher) handlePushEvent(e *github.PushEvent, l *logrus.Entry) {
-	defer d.wg.Done()
+func (d *dispatcher) handlePushEvent(e *github.PushEvent, dv delivery, l *logrus.Entry) {
 	l = l.WithFields(logrus.Fields{
 		logFieldOrg:  e.GetRepo().GetOwner().GetLogin(),
 		logFieldRepo: e.GetRepo().GetName(),
@@ -115,31 +463,29 @@ func (d *dispatcher) handlePushEvent(e *github.PushEvent, l *logrus.Entry) {
 		"head":       e.GetAfter(),
 	})
 
-	if err := d.h.pushEventHandler(e, d.getConfig(), l); err != nil {
-		l.WithError(err).Error()
-	} else {
-		l.Info()
+	cfg := d.getConfig()
+	for i, h := range d.h.pushEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
 	}
 }
 
-func (d *dispatcher) handleIssueCommentEvent(e *github.IssueCommentEvent, l *logrus.Entry) {
-	defer d.wg.Done()
-
+func (d *dispatcher) handleIssueCommentEvent(e *github.IssueCommentEvent, dv delivery, l *logrus.Entry) {
 	l = l.WithFields(logrus.Fields{
 		logFieldURL:    e.GetIssue().GetHTMLURL(),
 		logFieldAction: e.GetAction(),
 	})
 
-	if err := d.h.issueCommentHandler(e, d.getConfig(), l); err != nil {
-		l.WithError(err).Error()
-	} else {
-		l.Info()
+	cfg := d.getConfig()
+	for i, h := range d.h.issueCommentHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
 	}
 }
 
-func (d *dispatcher) handleStatusEvent(e *github.StatusEvent, l *logrus.Entry) {
-	defer d.wg.Done()
-
+func (d *dispatcher) handleStatusEvent(e *github.StatusEvent, dv delivery, l *logrus.Entry) {
 	org, repo := client.GetOrgRepo(e.GetRepo())
 	l = l.WithFields(logrus.Fields{
 		logFieldOrg:  org,
@@ -150,16 +496,15 @@ func (d *dispatcher) handleStatusEvent(e *github.StatusEvent, l *logrus.Entry) {
 		"id":         e.GetID(),
 	})
 
-	if err := d.h.statusEventHandler(e, d.getConfig(), l); err != nil {
-		l.WithError(err).Error()
-	} else {
-		l.Info()
+	cfg := d.getConfig()
+	for i, h := range d.h.statusEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
 	}
 }
 
-func (d *dispatcher) handleReviewEvent(e *github.PullRequestReviewEvent, l *logrus.Entry) {
-	defer d.wg.Done()
-
+func (d *dispatcher) handleReviewEvent(e *github.PullRequestReviewEvent, dv delivery, l *logrus.Entry) {
 	org, repo := client.GetOrgRepo(e.GetRepo())
 	l = l.WithFields(logrus.Fields{
 		logFieldOrg:  org,
@@ -169,16 +514,15 @@ func (d *dispatcher) handleReviewEvent(e *github.PullRequestReviewEvent, l *logr
 		"url":        e.GetReview().GetHTMLURL(),
 	})
 
-	if err := d.h.reviewEventHandler(e, d.getConfig(), l); err != nil {
-		l.WithError(err).Error()
-	} else {
-		l.Info()
+	cfg := d.getConfig()
+	for i, h := range d.h.reviewEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
 	}
 }
 
-func (d *dispatcher) handleReviewCommentEvent(e *github.PullRequestReviewCommentEvent, l *logrus.Entry) {
-	defer d.wg.Done()
-
+func (d *dispatcher) handleReviewCommentEvent(e *github.PullRequestReviewCommentEvent, dv delivery, l *logrus.Entry) {
 	org, repo := client.GetOrgRepo(e.GetRepo())
 	l = l.WithFields(logrus.Fields{
 		logFieldOrg:  org,
@@ -188,16 +532,15 @@ func (d *dispatcher) handleReviewCommentEvent(e *github.PullRequestReviewComment
 		"url":        e.GetComment().GetHTMLURL(),
 	})
 
-	if err := d.h.reviewCommentEventHandler(e, d.getConfig(), l); err != nil {
-		l.WithError(err).Error()
-	} else {
-		l.Info()
+	cfg := d.getConfig()
+	for i, h := range d.h.reviewCommentEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
 	}
 }
 
-func (d *dispatcher) handleCommitCommentEvent(e *github.CommitCommentEvent, l *logrus.Entry) {
-	defer d.wg.Done()
-
+func (d *dispatcher) handleCommitCommentEvent(e *github.CommitCommentEvent, dv delivery, l *logrus.Entry) {
 	org, repo := client.GetOrgRepo(e.GetRepo())
 	l = l.WithFields(logrus.Fields{
 		logFieldOrg:  org,
@@ -207,28 +550,519 @@ func (d *dispatcher) handleCommitCommentEvent(e *github.CommitCommentEvent, l *l
 		"url":        e.GetComment().GetHTMLURL(),
 	})
 
-	if err := d.h.commitCommentEventHandler(e, d.getConfig(), l); err != nil {
-		l.WithError(err).Error()
-	} else {
-		l.Info()
+	cfg := d.getConfig()
+	for i, h := range d.h.commitCommentEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
 	}
 }
 
-func (d *dispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	eventType, eventGUID, payload, ok := parseRequest(w, r)
-	if !ok {
+func (d *dispatcher) handleCheckRunEvent(e *github.CheckRunEvent, dv delivery, l *logrus.Entry) {
+	org, repo := client.GetOrgRepo(e.GetRepo())
+	l = l.WithFields(logrus.Fields{
+		logFieldOrg:    org,
+		logFieldRepo:   repo,
+		logFieldAction: e.GetAction(),
+		"check_run":    e.GetCheckRun().GetName(),
+		"sha":          e.GetCheckRun().GetHeadSHA(),
+		"status":       e.GetCheckRun().GetStatus(),
+		"conclusion":   e.GetCheckRun().GetConclusion(),
+		logFieldURL:    e.GetCheckRun().GetHTMLURL(),
+	})
+
+	cfg := d.getConfig()
+	for i, h := range d.h.checkRunEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
+	}
+}
+
+func (d *dispatcher) handleCheckSuiteEvent(e *github.CheckSuiteEvent, dv delivery, l *logrus.Entry) {
+	org, repo := client.GetOrgRepo(e.GetRepo())
+	l = l.WithFields(logrus.Fields{
+		logFieldOrg:    org,
+		logFieldRepo:   repo,
+		logFieldAction: e.GetAction(),
+		"check_suite":  e.GetCheckSuite().GetID(),
+		"branch":       e.GetCheckSuite().GetHeadBranch(),
+		"sha":          e.GetCheckSuite().GetHeadSHA(),
+		"status":       e.GetCheckSuite().GetStatus(),
+		"conclusion":   e.GetCheckSuite().GetConclusion(),
+	})
+
+	cfg := d.getConfig()
+	for i, h := range d.h.checkSuiteEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
+	}
+}
+
+func (d *dispatcher) handleWorkflowRunEvent(e *github.WorkflowRunEvent, dv delivery, l *logrus.Entry) {
+	org, repo := client.GetOrgRepo(e.GetRepo())
+	l = l.WithFields(logrus.Fields{
+		logFieldOrg:    org,
+		logFieldRepo:   repo,
+		logFieldAction: e.GetAction(),
+		"workflow":     e.GetWorkflowRun().GetName(),
+		"run":          e.GetWorkflowRun().GetID(),
+		"branch":       e.GetWorkflowRun().GetHeadBranch(),
+		"sha":          e.GetWorkflowRun().GetHeadSHA(),
+		"status":       e.GetWorkflowRun().GetStatus(),
+		"conclusion":   e.GetWorkflowRun().GetConclusion(),
+		logFieldURL:    e.GetWorkflowRun().GetHTMLURL(),
+	})
+
+	cfg := d.getConfig()
+	for i, h := range d.h.workflowRunEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
+	}
+}
+
+func (d *dispatcher) handleCreateEvent(e *github.CreateEvent, dv delivery, l *logrus.Entry) {
+	org, repo := client.GetOrgRepo(e.GetRepo())
+	l = l.WithFields(logrus.Fields{
+		logFieldOrg:  org,
+		logFieldRepo: repo,
+		"ref":        e.GetRef(),
+		"ref_type":   e.GetRefType(),
+		"sender":     e.GetSender().GetLogin(),
+	})
+
+	cfg := d.getConfig()
+	for i, h := range d.h.createEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
+	}
+}
+
+func (d *dispatcher) handleDeleteEvent(e *github.DeleteEvent, dv delivery, l *logrus.Entry) {
+	org, repo := client.GetOrgRepo(e.GetRepo())
+	l = l.WithFields(logrus.Fields{
+		logFieldOrg:  org,
+		logFieldRepo: repo,
+		"ref":        e.GetRef(),
+		"ref_type":   e.GetRefType(),
+		"sender":     e.GetSender().GetLogin(),
+	})
+
+	cfg := d.getConfig()
+	for i, h := range d.h.deleteEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
+	}
+}
+
+func (d *dispatcher) handleForkEvent(e *github.ForkEvent, dv delivery, l *logrus.Entry) {
+	org, repo := client.GetOrgRepo(e.GetRepo())
+	l = l.WithFields(logrus.Fields{
+		logFieldOrg:  org,
+		logFieldRepo: repo,
+		"forkee":     e.GetForkee().GetFullName(),
+		"sender":     e.GetSender().GetLogin(),
+	})
+
+	cfg := d.getConfig()
+	for i, h := range d.h.forkEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
+	}
+}
+
+func (d *dispatcher) handleRepositoryEvent(e *github.RepositoryEvent, dv delivery, l *logrus.Entry) {
+	org, repo := client.GetOrgRepo(e.GetRepo())
+	l = l.WithFields(logrus.Fields{
+		logFieldOrg:    org,
+		logFieldRepo:   repo,
+		logFieldAction: e.GetAction(),
+		"sender":       e.GetSender().GetLogin(),
+		logFieldURL:    e.GetRepo().GetHTMLURL(),
+	})
+
+	cfg := d.getConfig()
+	for i, h := range d.h.repositoryEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
+	}
+}
+
+func (d *dispatcher) handleMemberEvent(e *github.MemberEvent, dv delivery, l *logrus.Entry) {
+	org, repo := client.GetOrgRepo(e.GetRepo())
+	l = l.WithFields(logrus.Fields{
+		logFieldOrg:    org,
+		logFieldRepo:   repo,
+		logFieldAction: e.GetAction(),
+		"member":       e.GetMember().GetLogin(),
+	})
+
+	cfg := d.getConfig()
+	for i, h := range d.h.memberEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
+	}
+}
+
+func (d *dispatcher) handleLabelEvent(e *github.LabelEvent, dv delivery, l *logrus.Entry) {
+	org, repo := client.GetOrgRepo(e.GetRepo())
+	if org == "" {
+		org = e.GetOrg().GetLogin()
+	}
+
+	l = l.WithFields(logrus.Fields{
+		logFieldOrg:    org,
+		logFieldRepo:   repo,
+		logFieldAction: e.GetAction(),
+		"label":        e.GetLabel().GetName(),
+	})
+
+	cfg := d.getConfig()
+	for i, h := range d.h.labelEventHandlers {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
+	}
+}
+
+func (d *dispatcher) handleGenericEvent(
+	e *GenericEvent, hs []GenericHandler, dv delivery, l *logrus.Entry,
+) {
+	l = l.WithField("generic", true)
+
+	cfg := d.getConfig()
+	for i, h := range hs {
+		d.runHandler(dv, e, cfg, i, l, func(ev *Event) error {
+			return h(e, ev.Config, ev.Log)
+		})
+	}
+}
+
+// enqueue puts the task to the queue. It returns errQueueFull instead of
+// blocking when the queue is full.
+// The tasks waiting for the running task of same key are counted in the queue.
+func (d *dispatcher) enqueue(t task) error {
+	return d.put(t, false)
+}
+
+// put claims the key of task and puts it to the queue. If the key has been
+// claimed by a queued or running task, the task is held in pending and will be
+// processed after that one, so the tasks of same key are processed in the order
+// of put. It blocks when the queue is full if block is true.
+func (d *dispatcher) put(t task, block bool) error {
+	d.pendingMu.Lock()
+
+	if !block && len(d.queue)+d.pendingNum >= cap(d.queue) {
+		d.pendingMu.Unlock()
+
+		return errQueueFull
+	}
+
+	d.addTask()
+
+	if t.key != "" {
+		if v, ok := d.pending[t.key]; ok {
+			d.pending[t.key] = append(v, t)
+			d.pendingNum++
+			d.pendingMu.Unlock()
+
+			return nil
+		}
+
+		d.pending[t.key] = nil
+	}
+
+	if block {
+		d.pendingMu.Unlock()
+		d.queue <- t
+
+		return nil
+	}
+
+	defer d.pendingMu.Unlock()
+
+	select {
+	case d.queue <- t:
+		return nil
+	default:
+		if t.key != "" {
+			delete(d.pending, t.key)
+		}
+
+		d.doneTask()
+
+		return errQueueFull
+	}
+}
+
+// addTask counts a task accepted for handling, which is done by doneTask.
+func (d *dispatcher) addTask() {
+	d.wg.Add(1)
+	d.metrics.taskAdded()
+}
+
+func (d *dispatcher) doneTask() {
+	d.metrics.taskDone()
+	d.wg.Done()
+}
+
+func (d *dispatcher) work() {
+	for t := range d.queue {
+		for ok := true; ok; t, ok = d.release(t.key) {
+			d.process(t)
+		}
+	}
+}
+
+// release returns the next task of the key if there is, otherwise the key is
+// released.
+func (d *dispatcher) release(key string) (task, bool) {
+	if key == "" {
+		return task{}, false
+	}
+
+	d.pendingMu.Lock()
+	defer d.pendingMu.Unlock()
+
+	v := d.pending[key]
+	if len(v) == 0 {
+		delete(d.pending, key)
+
+		return task{}, false
+	}
+
+	d.pending[key] = v[1:]
+	d.pendingNum--
+
+	return v[0], true
+}
+
+// eventKey returns the key of the PR or issue which the event belongs to.
+// It returns empty string if the event doesn't belong to any PR or issue.
+func eventKey(e interface{}) string {
+	info := client.GenIssuePRInfo(e)
+	if info == nil {
+		return ""
+	}
+
+	org, repo := info.GetOrgRepo()
+
+	return client.PRInfo{Org: org, Repo: repo, Number: info.GetNumber()}.String()
+}
+
+// process runs the task. A panic raised during handling is recovered,
+// so that it will not crash the process or block the graceful shutdown.
+func (d *dispatcher) process(t task) {
+	defer d.doneTask()
+
+	if d.ctx.Err() != nil {
+		if d.journal != nil {
+			t.log.Info("skip the event on shutdown, it will be replayed at next startup")
+
+			return
+		}
+
+		t.log.Error("handle the event after shutdown, it may be lost because the handlers are cancelled")
+	}
+
+	defer d.removeJournalEntry(t)
+	defer d.recoverPanic(t.dv, t.log)
+
+	t.handle()
+}
+
+func (d *dispatcher) removeJournalEntry(t task) {
+	if err := d.journal.remove(t.entry); err != nil {
+		t.log.WithError(err).Error("remove journal entry")
+	}
+}
+
+// recoverPanic must be called by defer directly.
+func (d *dispatcher) recoverPanic(dv delivery, l *logrus.Entry) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	d.metrics.panicRecovered(d.name, dv.eventType)
+
+	l.WithFields(logrus.Fields{
+		logFieldEventType: dv.eventType,
+		logFieldEventGUID: dv.guid,
+		"panic":           r,
+		"stack":           string(debug.Stack()),
+	}).Error("recovered from panic of handler")
+}
+
+// robotLog adds the name of robot to the log if there are multiple robots.
+func (d *dispatcher) robotLog(l *logrus.Entry) *logrus.Entry {
+	if d.name == "" {
+		return l
+	}
+
+	return l.WithField(logFieldRobot, d.name)
+}
+
+// handlerLog returns the log entry for the index-th handler of an event.
+func handlerLog(l *logrus.Entry, index int) *logrus.Entry {
+	return l.WithField(logFieldHandler, index)
+}
+
+// runHandler calls the index-th handler through the middlewares and logs the
+// result. The handler is called again with backoff if it returns a retryable
+// error, and the event is put to the dead letter box if it still fails at last.
+func (d *dispatcher) runHandler(
+	dv delivery, e interface{}, cfg config.Config, index int, l *logrus.Entry, fn HandlerFunc,
+) {
+	_, generic := e.(*GenericEvent)
+	id := handlerID(d.name, generic, index)
+
+	if dv.handler != "" && dv.handler != id {
 		return
 	}
 
-	l := logrus.WithFields(
-		logrus.Fields{
-			"event-type": eventType,
-			"event_id":   eventGUID,
-		},
+	l = handlerLog(l, index)
+
+	defer d.recoverPanic(dv, l)
+
+	sctx, span := d.tracer.Start(
+		trace.ContextWithSpanContext(context.Background(), dv.span),
+		"handle "+dv.eventType,
+		eventAttributes(dv),
+		trace.WithAttributes(attrHandler.String(id)),
 	)
+	defer span.End()
 
-	if err := d.Dispatch(eventType, payload, l); err != nil {
+	call := chainMiddlewares(func(ev *Event) error {
+		ev.Log = ev.Log.WithContext(ev.Context)
+
+		return fn(ev)
+	}, d.middlewares)
+
+	attempt := func() (*logrus.Entry, error) {
+		ctx, cancel := d.handlerContext()
+		defer cancel()
+
+		ctx = trace.ContextWithSpan(ctx, trace.SpanFromContext(sctx))
+
+		ev := &Event{
+			Type:    dv.eventType,
+			GUID:    dv.guid,
+			Payload: e,
+			Config:  cfg,
+			Log:     l,
+			Context: ctx,
+		}
+
+		err := call(ev)
+
+		return ev.Log, err
+	}
+
+	start := time.Now()
+
+	hl, err := attempt()
+
+	n := 1
+	for ; err != nil && IsRetryableError(err) && n <= d.retry.maxRetries; n++ {
+		delay := d.retry.backoff(n)
+		hl.WithError(err).Warnf("retry the handler after %s", delay)
+		span.AddEvent("retry", trace.WithAttributes(
+			attrAttempts.Int(n), attribute.String("error", err.Error()),
+		))
+
+		if !d.sleep(delay) {
+			break
+		}
+
+		hl, err = attempt()
+	}
+
+	logResult(err, hl)
+	d.metrics.handlerCalled(dv.eventType, id, start, err)
+
+	span.SetAttributes(attrAttempts.Int(n))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	if err != nil && IsRetryableError(err) {
+		d.saveDeadLetter(dv, generic, index, n, err, hl)
+	}
+}
+
+// handlerContext returns the context for a call of handler.
+func (d *dispatcher) handlerContext() (context.Context, context.CancelFunc) {
+	if d.timeout > 0 {
+		return context.WithTimeout(d.ctx, d.timeout)
+	}
+
+	return context.WithCancel(d.ctx)
+}
+
+// sleep waits for the duration. It returns false if the dispatcher is stopped.
+func (d *dispatcher) sleep(delay time.Duration) bool {
+	t := time.NewTimer(delay)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-d.ctx.Done():
+		return false
+	}
+}
+
+func (d *dispatcher) saveDeadLetter(
+	dv delivery, generic bool, index, attempts int, err error, l *logrus.Entry,
+) {
+	path, err1 := d.deadLetter.save(&DeadLetter{
+		EventType: dv.eventType,
+		GUID:      dv.guid,
+		Payload:   dv.payload,
+		Robot:     d.name,
+		Generic:   generic,
+		Handler:   index,
+		Attempts:  attempts,
+		LastError: err.Error(),
+		FailedAt:  time.Now(),
+	})
+	if err1 != nil {
+		l.WithError(err1).Error("save dead letter")
+	} else if path != "" {
+		l.WithField("dead_letter", path).Warn("the event is put to dead letter box")
+	}
+}
+
+// logResult writes one log entry for the result of a handler.
+func logResult(err error, l *logrus.Entry) {
+	if err != nil {
 		l.WithError(err).Error()
+	} else {
+		l.Info()
+	}
+}
+
+// queueStatus is the status of event queue for monitoring.
+type queueStatus struct {
+	Depth    int `json:"depth"`
+	Capacity int `json:"capacity"`
+	Workers  int `json:"workers"`
+}
+
+func (d *dispatcher) queueStatus() queueStatus {
+	return queueStatus{
+		Depth:    d.QueueDepth(),
+		Capacity: cap(d.queue),
+		Workers:  d.workers,
 	}
 }
 
@@ -239,7 +1073,7 @@ func parseRequest(w http.ResponseWriter, r *http.Request) (eventType string, uui
 		http.Error(w, msg, code)
 	}
 
-	if r.Header.Get("User-Agent") != "Robot-Github-Access" {
+	if !isTrustedRelay(r) {
 		resp(http.StatusBadRequest, "400 Bad Request: unknown User-Agent Header")
 		return
 	}
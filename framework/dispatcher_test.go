@@ -0,0 +1,218 @@
+package framework
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestDispatcher(workers, queueSize int) *dispatcher {
+	o := newRunOptions([]Option{WithWorkers(workers), WithQueueSize(queueSize)})
+
+	return newDispatcher("", nil, handlers{}, &o)
+}
+
+func newTestTask(key string, handle func()) task {
+	return task{key: key, log: logrus.NewEntry(logrus.New()), handle: handle}
+}
+
+// waitDone fails the test if the dispatcher doesn't finish the tasks in time.
+func waitDone(t *testing.T, d *dispatcher) {
+	if !d.WaitTimeout(5 * time.Second) {
+		t.Fatal("the tasks are not done in time")
+	}
+}
+
+func TestDispatcherQueue(t *testing.T) {
+	cases := []struct {
+		name      string
+		workers   int
+		queueSize int
+		run       func(t *testing.T, d *dispatcher)
+	}{
+		{
+			name:      "same key in order",
+			workers:   8,
+			queueSize: 1000,
+			run: func(t *testing.T, d *dispatcher) {
+				var mu sync.Mutex
+				handled := map[string][]int{}
+
+				for i := 0; i < 400; i++ {
+					key, seq := fmt.Sprintf("key-%d", i%4), i
+
+					err := d.enqueue(newTestTask(key, func() {
+						time.Sleep(time.Duration(seq%3) * time.Millisecond)
+
+						mu.Lock()
+						handled[key] = append(handled[key], seq)
+						mu.Unlock()
+					}))
+					if err != nil {
+						t.Fatalf("enqueue() = %v", err)
+					}
+				}
+
+				waitDone(t, d)
+
+				for key, v := range handled {
+					if len(v) != 100 {
+						t.Errorf("%s: handled %d tasks, want 100", key, len(v))
+					}
+
+					for i := 1; i < len(v); i++ {
+						if v[i] < v[i-1] {
+							t.Errorf("%s: handled out of order: %v", key, v)
+
+							break
+						}
+					}
+				}
+
+				// the key is released after the last task of it is done.
+				deadline := time.Now().Add(time.Second)
+				for {
+					d.pendingMu.Lock()
+					n, num := len(d.pending), d.pendingNum
+					d.pendingMu.Unlock()
+
+					if n == 0 && num == 0 {
+						break
+					}
+
+					if time.Now().After(deadline) {
+						t.Fatalf("keys are not released: %d, %d", n, num)
+					}
+
+					time.Sleep(time.Millisecond)
+				}
+			},
+		},
+		{
+			name:      "different keys in parallel",
+			workers:   2,
+			queueSize: 10,
+			run: func(t *testing.T, d *dispatcher) {
+				var started sync.WaitGroup
+				started.Add(2)
+
+				both := make(chan struct{})
+				go func() {
+					started.Wait()
+					close(both)
+				}()
+
+				for _, key := range []string{"a", "b"} {
+					err := d.enqueue(newTestTask(key, func() {
+						started.Done()
+
+						select {
+						case <-both:
+						case <-time.After(5 * time.Second):
+							t.Error("the tasks of different keys are not run in parallel")
+						}
+					}))
+					if err != nil {
+						t.Fatalf("enqueue() = %v", err)
+					}
+				}
+
+				waitDone(t, d)
+			},
+		},
+		{
+			name:      "pending counted against queue size",
+			workers:   1,
+			queueSize: 2,
+			run: func(t *testing.T, d *dispatcher) {
+				started, gate := make(chan struct{}), make(chan struct{})
+
+				if err := d.enqueue(newTestTask("a", func() {
+					close(started)
+					<-gate
+				})); err != nil {
+					t.Fatalf("enqueue() = %v", err)
+				}
+
+				<-started
+
+				for i := 0; i < 2; i++ {
+					if err := d.enqueue(newTestTask("a", func() {})); err != nil {
+						t.Fatalf("enqueue() = %v", err)
+					}
+				}
+
+				if n := d.QueueDepth(); n != 2 {
+					t.Errorf("QueueDepth() = %d, want 2", n)
+				}
+
+				if err := d.enqueue(newTestTask("b", func() {})); err != errQueueFull {
+					t.Errorf("enqueue() = %v, want %v", err, errQueueFull)
+				}
+
+				close(gate)
+				waitDone(t, d)
+			},
+		},
+		{
+			name:      "rejected task does not claim its key",
+			workers:   1,
+			queueSize: 1,
+			run: func(t *testing.T, d *dispatcher) {
+				started, gate := make(chan struct{}), make(chan struct{})
+
+				if err := d.enqueue(newTestTask("a", func() {
+					close(started)
+					<-gate
+				})); err != nil {
+					t.Fatalf("enqueue() = %v", err)
+				}
+
+				<-started
+
+				if err := d.enqueue(newTestTask("b", func() {})); err != nil {
+					t.Fatalf("enqueue() = %v", err)
+				}
+
+				if err := d.enqueue(newTestTask("c", func() {})); err != errQueueFull {
+					t.Fatalf("enqueue() = %v, want %v", err, errQueueFull)
+				}
+
+				d.pendingMu.Lock()
+				_, claimed := d.pending["c"]
+				d.pendingMu.Unlock()
+
+				if claimed {
+					t.Error("the key of rejected task is claimed")
+				}
+
+				close(gate)
+				waitDone(t, d)
+
+				// the key is free, so the redelivery is queued and handled.
+				handled := false
+				if err := d.enqueue(newTestTask("c", func() { handled = true })); err != nil {
+					t.Fatalf("enqueue() = %v", err)
+				}
+
+				waitDone(t, d)
+
+				if !handled {
+					t.Error("the redelivery is not handled")
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := newTestDispatcher(c.workers, c.queueSize)
+			defer close(d.queue)
+
+			c.run(t, d)
+		})
+	}
+}
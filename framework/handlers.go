@@ -4,6 +4,7 @@ import (
 	"github.com/google/go-github/v36/github"
 	"github.com/opensourceways/server-common-lib/config"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 // IssueHandler defines the function contract for a github.IssuesEvent handler.
@@ -30,53 +31,189 @@ type ReviewCommentEventHandler func(e *github.PullRequestReviewCommentEvent, cfg
 // CommitCommentEventHandler defines the function contract for a github.CommitCommentEvent handler.
 type CommitCommentEventHandler func(e *github.CommitCommentEvent, cfg config.Config, log *logrus.Entry) error
 
+// CheckRunEventHandler defines the function contract for a github.CheckRunEvent handler.
+type CheckRunEventHandler func(e *github.CheckRunEvent, cfg config.Config, log *logrus.Entry) error
+
+// CheckSuiteEventHandler defines the function contract for a github.CheckSuiteEvent handler.
+type CheckSuiteEventHandler func(e *github.CheckSuiteEvent, cfg config.Config, log *logrus.Entry) error
+
+// WorkflowRunEventHandler defines the function contract for a github.WorkflowRunEvent handler.
+type WorkflowRunEventHandler func(e *github.WorkflowRunEvent, cfg config.Config, log *logrus.Entry) error
+
+// CreateEventHandler defines the function contract for a github.CreateEvent handler.
+type CreateEventHandler func(e *github.CreateEvent, cfg config.Config, log *logrus.Entry) error
+
+// DeleteEventHandler defines the function contract for a github.DeleteEvent handler.
+type DeleteEventHandler func(e *github.DeleteEvent, cfg config.Config, log *logrus.Entry) error
+
+// ForkEventHandler defines the function contract for a github.ForkEvent handler.
+type ForkEventHandler func(e *github.ForkEvent, cfg config.Config, log *logrus.Entry) error
+
+// RepositoryEventHandler defines the function contract for a github.RepositoryEvent handler.
+type RepositoryEventHandler func(e *github.RepositoryEvent, cfg config.Config, log *logrus.Entry) error
+
+// MemberEventHandler defines the function contract for a github.MemberEvent handler.
+type MemberEventHandler func(e *github.MemberEvent, cfg config.Config, log *logrus.Entry) error
+
+// LabelEventHandler defines the function contract for a github.LabelEvent handler.
+type LabelEventHandler func(e *github.LabelEvent, cfg config.Config, log *logrus.Entry) error
+
+// GenericEvent is a webhook event of any type.
+type GenericEvent struct {
+	// Type is the event type, which is the value of X-GitHub-Event header.
+	Type string
+
+	// GUID is the delivery ID, which is the value of X-GitHub-Delivery header.
+	GUID string
+
+	// Payload is the raw payload of the webhook.
+	Payload []byte
+
+	// Parsed is the event parsed by github.ParseWebHook, such as *github.IssuesEvent.
+	// It is nil if github.ParseWebHook fails, for example, the event type is unknown to it.
+	Parsed interface{}
+}
+
+// GenericHandler defines the function contract for a handler of any event type.
+type GenericHandler func(e *GenericEvent, cfg config.Config, log *logrus.Entry) error
+
+// AllEventTypes can be passed to RegisterGenericHandler to handle every event type.
+const AllEventTypes = "*"
+
+type genericHandler struct {
+	eventTypes sets.String
+	fn         GenericHandler
+}
+
+// handlers holds the registered handlers of each event kind. The handlers
+// of same event kind are run in the order of registration.
 type handlers struct {
-	issueHandlers             IssueHandler
-	pullRequestHandler        PullRequestHandler
-	pushEventHandler          PushEventHandler
-	issueCommentHandler       IssueCommentHandler
-	statusEventHandler        StatusEventHandler
-	reviewEventHandler        ReviewEventHandler
-	reviewCommentEventHandler ReviewCommentEventHandler
-	commitCommentEventHandler CommitCommentEventHandler
+	issueHandlers              []IssueHandler
+	pullRequestHandlers        []PullRequestHandler
+	pushEventHandlers          []PushEventHandler
+	issueCommentHandlers       []IssueCommentHandler
+	statusEventHandlers        []StatusEventHandler
+	reviewEventHandlers        []ReviewEventHandler
+	reviewCommentEventHandlers []ReviewCommentEventHandler
+	commitCommentEventHandlers []CommitCommentEventHandler
+	checkRunEventHandlers      []CheckRunEventHandler
+	checkSuiteEventHandlers    []CheckSuiteEventHandler
+	workflowRunEventHandlers   []WorkflowRunEventHandler
+	createEventHandlers        []CreateEventHandler
+	deleteEventHandlers        []DeleteEventHandler
+	forkEventHandlers          []ForkEventHandler
+	repositoryEventHandlers    []RepositoryEventHandler
+	memberEventHandlers        []MemberEventHandler
+	labelEventHandlers         []LabelEventHandler
+	genericHandlers            []genericHandler
 }
 
 // RegisterIssueHandler registers a plugin's github.IssueEvent handler.
 func (h *handlers) RegisterIssueHandler(fn IssueHandler) {
-	h.issueHandlers = fn
+	h.issueHandlers = append(h.issueHandlers, fn)
 }
 
 // RegisterPullRequestHandler registers a plugin's github.PullRequestEvent handler.
 func (h *handlers) RegisterPullRequestHandler(fn PullRequestHandler) {
-	h.pullRequestHandler = fn
+	h.pullRequestHandlers = append(h.pullRequestHandlers, fn)
 }
 
 // RegisterPushEventHandler registers a plugin's github.PushEvent handler.
 func (h *handlers) RegisterPushEventHandler(fn PushEventHandler) {
-	h.pushEventHandler = fn
+	h.pushEventHandlers = append(h.pushEventHandlers, fn)
 }
 
 // RegisterIssueCommentHandler registers a plugin's github.IssueCommentEvent handler.
 func (h *handlers) RegisterIssueCommentHandler(fn IssueCommentHandler) {
-	h.issueCommentHandler = fn
+	h.issueCommentHandlers = append(h.issueCommentHandlers, fn)
 }
 
 // RegisterStatusEventHandler registers a plugin's github.StatusEvent handler.
 func (h *handlers) RegisterStatusEventHandler(fn StatusEventHandler) {
-	h.statusEventHandler = fn
+	h.statusEventHandlers = append(h.statusEventHandlers, fn)
 }
 
 // RegisterReviewEventHandler registers a plugin's github.ReviewEvent handler.
 func (h *handlers) RegisterReviewEventHandler(fn ReviewEventHandler) {
-	h.reviewEventHandler = fn
+	h.reviewEventHandlers = append(h.reviewEventHandlers, fn)
 }
 
 // RegisterReviewCommentEventHandler registers a plugin's github.ReviewCommentEvent handler.
 func (h *handlers) RegisterReviewCommentEventHandler(fn ReviewCommentEventHandler) {
-	h.reviewCommentEventHandler = fn
+	h.reviewCommentEventHandlers = append(h.reviewCommentEventHandlers, fn)
 }
 
 // RegisterCommitCommentEventHandler registers a plugin's github.CommitCommentEvent handler.
 func (h *handlers) RegisterCommitCommentEventHandler(fn CommitCommentEventHandler) {
-	h.commitCommentEventHandler = fn
+	h.commitCommentEventHandlers = append(h.commitCommentEventHandlers, fn)
+}
+
+// RegisterCheckRunEventHandler registers a plugin's github.CheckRunEvent handler.
+func (h *handlers) RegisterCheckRunEventHandler(fn CheckRunEventHandler) {
+	h.checkRunEventHandlers = append(h.checkRunEventHandlers, fn)
+}
+
+// RegisterCheckSuiteEventHandler registers a plugin's github.CheckSuiteEvent handler.
+func (h *handlers) RegisterCheckSuiteEventHandler(fn CheckSuiteEventHandler) {
+	h.checkSuiteEventHandlers = append(h.checkSuiteEventHandlers, fn)
+}
+
+// RegisterWorkflowRunEventHandler registers a plugin's github.WorkflowRunEvent handler.
+func (h *handlers) RegisterWorkflowRunEventHandler(fn WorkflowRunEventHandler) {
+	h.workflowRunEventHandlers = append(h.workflowRunEventHandlers, fn)
+}
+
+// RegisterCreateEventHandler registers a plugin's github.CreateEvent handler.
+func (h *handlers) RegisterCreateEventHandler(fn CreateEventHandler) {
+	h.createEventHandlers = append(h.createEventHandlers, fn)
+}
+
+// RegisterDeleteEventHandler registers a plugin's github.DeleteEvent handler.
+func (h *handlers) RegisterDeleteEventHandler(fn DeleteEventHandler) {
+	h.deleteEventHandlers = append(h.deleteEventHandlers, fn)
+}
+
+// RegisterForkEventHandler registers a plugin's github.ForkEvent handler.
+func (h *handlers) RegisterForkEventHandler(fn ForkEventHandler) {
+	h.forkEventHandlers = append(h.forkEventHandlers, fn)
+}
+
+// RegisterRepositoryEventHandler registers a plugin's github.RepositoryEvent handler.
+func (h *handlers) RegisterRepositoryEventHandler(fn RepositoryEventHandler) {
+	h.repositoryEventHandlers = append(h.repositoryEventHandlers, fn)
+}
+
+// RegisterMemberEventHandler registers a plugin's github.MemberEvent handler.
+func (h *handlers) RegisterMemberEventHandler(fn MemberEventHandler) {
+	h.memberEventHandlers = append(h.memberEventHandlers, fn)
+}
+
+// RegisterLabelEventHandler registers a plugin's github.LabelEvent handler.
+func (h *handlers) RegisterLabelEventHandler(fn LabelEventHandler) {
+	h.labelEventHandlers = append(h.labelEventHandlers, fn)
+}
+
+// RegisterGenericHandler registers a plugin's handler for the event types, which
+// can be any type, including the ones not supported by the typed handlers.
+// Pass AllEventTypes to handle every event type.
+func (h *handlers) RegisterGenericHandler(eventTypes []string, fn GenericHandler) {
+	h.genericHandlers = append(h.genericHandlers, genericHandler{
+		eventTypes: sets.NewString(eventTypes...),
+		fn:         fn,
+	})
+}
+
+// genericHandlersOf returns the generic handlers registered for the event type.
+func (h *handlers) genericHandlersOf(eventType string) []GenericHandler {
+	var r []GenericHandler
+
+	for i := range h.genericHandlers {
+		item := &h.genericHandlers[i]
+
+		if item.eventTypes.Has(eventType) || item.eventTypes.Has(AllEventTypes) {
+			r = append(r, item.fn)
+		}
+	}
+
+	return r
 }
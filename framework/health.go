@@ -0,0 +1,281 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/opensourceways/server-common-lib/config"
+)
+
+var (
+	errDraining     = errors.New("draining")
+	errReadyTimeout = errors.New("readiness check timed out")
+)
+
+// readyCheckTimeout is how long a call of ReadyChecker can take.
+const readyCheckTimeout = 3 * time.Second
+
+// ReadyChecker can be implemented by a Robot to report whether it is ready to
+// handle events, for example whether it can access GitHub by GetBot.
+type ReadyChecker interface {
+	Ready() error
+}
+
+// health serves the probes of liveness and readiness.
+type health struct {
+	robots []*robotProbe
+
+	// draining is set to 1 once the shutdown begins.
+	draining int32
+}
+
+// robotProbe is the readiness of a robot.
+type robotProbe struct {
+	name  string
+	agent *config.ConfigAgent
+	bot   Robot
+
+	// ttl is how long the result of ReadyChecker is reused.
+	ttl time.Duration
+
+	// timeout is how long a call of ReadyChecker can take.
+	timeout time.Duration
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+
+	// checking is closed when the running call of ReadyChecker returns.
+	// It is nil if no call is running.
+	checking chan struct{}
+	deadline time.Time
+}
+
+// loaded tells whether the config of robot is loaded.
+func (p *robotProbe) loaded() error {
+	if _, cfg := p.agent.GetConfig(); cfg == nil {
+		return errors.New("config is not loaded")
+	}
+
+	return nil
+}
+
+// check returns the result of ReadyChecker if the robot implements it.
+// The concurrent probes share one call of Ready, which runs in background
+// and the last result is returned meanwhile if it is cached. The probe fails
+// if the call doesn't return within the timeout.
+func (p *robotProbe) check() error {
+	c, ok := p.bot.(ReadyChecker)
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+
+	p.mu.Lock()
+
+	if !p.checkedAt.IsZero() && now.Sub(p.checkedAt) < p.ttl {
+		err := p.err
+		p.mu.Unlock()
+
+		return err
+	}
+
+	if p.checking == nil {
+		p.checking = make(chan struct{})
+		p.deadline = now.Add(p.timeout)
+
+		go p.runCheck(c, p.checking)
+	}
+
+	checking, deadline := p.checking, p.deadline
+	checked, err := !p.checkedAt.IsZero(), p.err
+
+	p.mu.Unlock()
+
+	if !now.Before(deadline) {
+		return errReadyTimeout
+	}
+
+	// the last result is not reused if the cache is disabled.
+	if checked && p.ttl > 0 {
+		return err
+	}
+
+	timer := time.NewTimer(deadline.Sub(now))
+	defer timer.Stop()
+
+	select {
+	case <-checking:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		return p.err
+
+	case <-timer.C:
+		return errReadyTimeout
+	}
+}
+
+func (p *robotProbe) runCheck(c ReadyChecker, checking chan struct{}) {
+	err := c.Ready()
+
+	p.mu.Lock()
+	p.err = err
+	p.checkedAt = time.Now()
+	p.checking = nil
+	p.mu.Unlock()
+
+	close(checking)
+}
+
+// wrapError adds the name of robot to err if it is hosted with other robots.
+func (p *robotProbe) wrapError(err error) error {
+	if err == nil || p.name == "" {
+		return err
+	}
+
+	return fmt.Errorf("%s: %w", p.name, err)
+}
+
+// drain makes the readiness probe fail, so that no more webhooks are sent
+// during the shutdown.
+func (h *health) drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
+
+// ready tells whether the service can receive webhooks. The ReadyChecker of
+// robot is taken into account only if there is one robot, so that a failing
+// robot will not stop the webhooks of the others. See robotReady.
+func (h *health) ready() error {
+	if atomic.LoadInt32(&h.draining) == 1 {
+		return errDraining
+	}
+
+	for _, p := range h.robots {
+		if err := p.loaded(); err != nil {
+			return p.wrapError(err)
+		}
+	}
+
+	if len(h.robots) == 1 {
+		p := h.robots[0]
+
+		return p.wrapError(p.check())
+	}
+
+	return nil
+}
+
+// robotReady tells whether the robot is ready, including its ReadyChecker.
+func (h *health) robotReady(p *robotProbe) error {
+	if atomic.LoadInt32(&h.draining) == 1 {
+		return errDraining
+	}
+
+	if err := p.loaded(); err != nil {
+		return err
+	}
+
+	return p.check()
+}
+
+func (h *health) robot(name string) *robotProbe {
+	for _, p := range h.robots {
+		if p.name == name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+func (h *health) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+// serveReadyz serves the readiness of service, or the one of a robot if the
+// robot is specified by the query, such as /readyz?robot=name. The readiness
+// of each robot is listed if there are multiple robots.
+func (h *health) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	if name := r.URL.Query().Get("robot"); name != "" {
+		p := h.robot(name)
+		if p == nil {
+			http.Error(w, "404 Not Found: unknown robot", http.StatusNotFound)
+
+			return
+		}
+
+		writeReadiness(w, h.robotReady(p), "")
+
+		return
+	}
+
+	details := ""
+	if len(h.robots) > 1 {
+		var b strings.Builder
+		for _, p := range h.robots {
+			status := "ok"
+			if err := h.robotReady(p); err != nil {
+				status = err.Error()
+			}
+
+			fmt.Fprintf(&b, "%s: %s\n", p.name, status)
+		}
+
+		details = b.String()
+	}
+
+	writeReadiness(w, h.ready(), details)
+}
+
+func writeReadiness(w http.ResponseWriter, err error, details string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "503 Service Unavailable: %s", err.Error())
+	} else {
+		w.Write([]byte("ok"))
+	}
+
+	if details != "" {
+		fmt.Fprintf(w, "\n\n%s", details)
+	}
+}
+
+// drainingServer fails the readiness probe and waits delay before shutting
+// down the server, so that the webhooks are not sent to it any more when
+// it stops accepting them.
+type drainingServer struct {
+	*http.Server
+
+	health      *health
+	delay       time.Duration
+	gracePeriod time.Duration
+
+	// stopped is closed when the server is shut down.
+	stopped chan struct{}
+}
+
+// Shutdown implements interrupts.Shutdownable. The ctx is ignored, because
+// its timeout begins before the delay.
+func (s *drainingServer) Shutdown(_ context.Context) error {
+	defer close(s.stopped)
+
+	s.health.drain()
+
+	time.Sleep(s.delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.gracePeriod)
+	defer cancel()
+
+	return s.Server.Shutdown(ctx)
+}
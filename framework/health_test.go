@@ -0,0 +1,118 @@
+package framework
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeReadyRobot struct {
+	Robot
+
+	calls int32
+	block chan struct{}
+	err   error
+}
+
+func (r *fakeReadyRobot) Ready() error {
+	atomic.AddInt32(&r.calls, 1)
+
+	if r.block != nil {
+		<-r.block
+	}
+
+	return r.err
+}
+
+func TestRobotProbeCheck(t *testing.T) {
+	errBadToken := errors.New("bad token")
+
+	t.Run("cached", func(t *testing.T) {
+		bot := &fakeReadyRobot{err: errBadToken}
+		p := &robotProbe{bot: bot, ttl: time.Minute, timeout: time.Second}
+
+		for i := 0; i < 3; i++ {
+			if err := p.check(); err != errBadToken {
+				t.Fatalf("check() = %v, want %v", err, errBadToken)
+			}
+		}
+
+		if n := atomic.LoadInt32(&bot.calls); n != 1 {
+			t.Errorf("Ready is called %d times, want 1", n)
+		}
+	})
+
+	t.Run("not cached", func(t *testing.T) {
+		bot := &fakeReadyRobot{}
+		p := &robotProbe{bot: bot, timeout: time.Second}
+
+		for i := 0; i < 3; i++ {
+			if err := p.check(); err != nil {
+				t.Fatalf("check() = %v", err)
+			}
+		}
+
+		if n := atomic.LoadInt32(&bot.calls); n != 3 {
+			t.Errorf("Ready is called %d times, want 3", n)
+		}
+	})
+
+	t.Run("hung", func(t *testing.T) {
+		bot := &fakeReadyRobot{block: make(chan struct{})}
+		p := &robotProbe{bot: bot, timeout: 50 * time.Millisecond}
+
+		start := time.Now()
+		errs := make(chan error, 3)
+		for i := 0; i < 3; i++ {
+			go func() { errs <- p.check() }()
+		}
+
+		for i := 0; i < 3; i++ {
+			if err := <-errs; err != errReadyTimeout {
+				t.Errorf("check() = %v, want %v", err, errReadyTimeout)
+			}
+		}
+
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("check() blocks for %s", d)
+		}
+
+		if err := p.check(); err != errReadyTimeout {
+			t.Errorf("check() after the deadline = %v, want %v", err, errReadyTimeout)
+		}
+
+		if n := atomic.LoadInt32(&bot.calls); n != 1 {
+			t.Errorf("Ready is called %d times, want 1", n)
+		}
+
+		close(bot.block)
+
+		deadline := time.Now().Add(time.Second)
+		for p.check() != nil {
+			if time.Now().After(deadline) {
+				t.Fatal("check() doesn't recover after Ready returns")
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("last result while checking", func(t *testing.T) {
+		bot := &fakeReadyRobot{}
+		p := &robotProbe{bot: bot, ttl: time.Nanosecond, timeout: time.Second}
+
+		if err := p.check(); err != nil {
+			t.Fatalf("check() = %v", err)
+		}
+
+		bot.block = make(chan struct{})
+		defer close(bot.block)
+
+		bot.err = errBadToken
+
+		if err := p.check(); err != nil {
+			t.Errorf("check() = %v, want the last result", err)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package framework
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/opensourceways/server-common-lib/config"
+	"github.com/sirupsen/logrus"
+
+	"github.com/opensourceways/robot-github-lib/client"
+)
+
+const helpCommandName = "help"
+
+// CommentClient is the part of client.Client used to reply the comments.
+type CommentClient interface {
+	CreateIssueComment(is client.PRInfo, comment string) error
+}
+
+// WithHelp registers the /help command which replies the available commands
+// of the repository by cli.
+func WithHelp(cli CommentClient) CommandRouterOption {
+	return func(r *CommandRouter) {
+		r.helpCli = cli
+	}
+}
+
+func (r *CommandRouter) helpCommand() Command {
+	return Command{
+		Name:        helpCommandName,
+		Description: "Show the available commands.",
+		Usage:       "/help",
+		Handler: func(c *CommandCall, cfg config.Config, log *logrus.Entry) error {
+			e := c.Event
+			org, repo := e.GetRepo().GetOwner().GetLogin(), e.GetRepo().GetName()
+
+			return r.helpCli.CreateIssueComment(
+				client.PRInfo{Org: org, Repo: repo, Number: e.GetIssue().GetNumber()},
+				r.Help(cfg, org, repo, c.IsPR),
+			)
+		},
+	}
+}
+
+// Help returns the Markdown table of commands which are enabled for the
+// repository and can be used on the PR or issue.
+func (r *CommandRouter) Help(cfg config.Config, org, repo string, isPR bool) string {
+	var cmds []*Command
+
+	for _, name := range r.names {
+		if c := r.commands[name]; c.isEnabled(cfg, org, repo) && c.canUseOn(isPR) {
+			cmds = append(cmds, c)
+		}
+	}
+
+	if len(cmds) == 0 {
+		return "No command is available here."
+	}
+
+	b := new(strings.Builder)
+	b.WriteString("| Command | Description | Usage | Permission |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+
+	for _, c := range cmds {
+		fmt.Fprintf(
+			b, "| %s | %s | %s | %s |\n",
+			"`/"+c.Name+"`", escapeCell(c.Description), usageCell(c), escapeCell(c.Permission.String()),
+		)
+	}
+
+	return b.String()
+}
+
+// Markdown returns the reference of all the commands as a Markdown document.
+func (r *CommandRouter) Markdown() string {
+	b := new(strings.Builder)
+	b.WriteString("# Commands\n\n")
+	b.WriteString("| Command | Description | Usage | Permission | Available on |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+
+	for _, name := range r.names {
+		c := r.commands[name]
+
+		fmt.Fprintf(
+			b, "| %s | %s | %s | %s | %s |\n",
+			"`/"+c.Name+"`", escapeCell(c.Description), usageCell(c),
+			escapeCell(c.Permission.String()), c.contextsDesc(),
+		)
+	}
+
+	return b.String()
+}
+
+func (c *Command) contextsDesc() string {
+	var v []string
+
+	if c.canUseOn(false) {
+		v = append(v, "issue")
+	}
+
+	if c.canUseOn(true) {
+		v = append(v, "pull request")
+	}
+
+	return strings.Join(v, ", ")
+}
+
+func usageCell(c *Command) string {
+	usage := c.Usage
+	if usage == "" {
+		usage = "/" + c.Name
+	}
+
+	return "`" + escapeCell(usage) + "`"
+}
+
+// escapeCell escapes the text to be put in a cell of Markdown table.
+func escapeCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
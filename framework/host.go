@@ -0,0 +1,183 @@
+package framework
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	deliveryStatusAccepted  = "accepted"
+	deliveryStatusDuplicate = "duplicate"
+)
+
+// host receives the webhooks and dispatches each of them to all the robots.
+type host struct {
+	auth    authenticator
+	tracer  trace.Tracer
+	metrics *metrics
+	robots  []*dispatcher
+
+	// unknownEventStatus is the status code responded for the unknown event type.
+	unknownEventStatus int
+}
+
+// ServeHTTP parses the payload once for all the robots. It responds 202 if any
+// robot accepts the event. But it responds an error if any robot fails to
+// accept it, so that the sender can redeliver it, which will be dropped as a
+// duplicate one by the robots which have accepted it.
+func (h *host) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	eventType, eventGUID, payload, ok := h.auth.parseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	l := logrus.WithFields(
+		logrus.Fields{
+			logFieldEventType: eventType,
+			logFieldEventGUID: eventGUID,
+		},
+	)
+
+	dv := delivery{
+		eventType: eventType,
+		guid:      eventGUID,
+		payload:   payload,
+		handler:   r.Header.Get(replayHandlerHeader),
+	}
+
+	ctx, span := h.tracer.Start(
+		r.Context(), "receive "+eventType,
+		trace.WithSpanKind(trace.SpanKindServer),
+		eventAttributes(dv),
+	)
+	defer span.End()
+
+	dv.span = trace.SpanContextFromContext(ctx)
+
+	if h.metrics != nil || span.IsRecording() {
+		s := summarize(payload)
+
+		h.metrics.eventReceived(eventType, s.Action)
+		span.SetAttributes(s.attributes()...)
+	}
+
+	p, err := parsePayload(dv)
+	if err != nil {
+		l.WithError(err).Error()
+		recordError(span, err)
+		writeError(w, err)
+
+		return
+	}
+
+	var (
+		accepted  bool
+		duplicate bool
+		invalid   error
+		failed    error
+	)
+
+	for _, d := range h.robots {
+		rl := d.robotLog(l)
+
+		err := d.receive(dv, p, rl)
+
+		switch {
+		case err == nil:
+			accepted = true
+
+		case errors.Is(err, errDuplicate):
+			rl.Debug("Dropping duplicate delivery")
+
+			duplicate = true
+
+		case errors.Is(err, errUnknownEvent):
+			rl.WithError(err).Debug("Ignoring unknown event type")
+
+		default:
+			rl.WithError(err).Error()
+			recordError(span, err)
+
+			if errors.Is(err, errInvalidPayload) {
+				invalid = err
+			} else if failed == nil || errors.Is(err, errQueueFull) {
+				failed = err
+			}
+		}
+	}
+
+	switch {
+	case failed != nil:
+		writeError(w, failed)
+
+	case accepted:
+		writeDeliveryResponse(w, eventGUID, deliveryStatusAccepted)
+
+	case duplicate:
+		span.AddEvent("duplicate delivery")
+
+		writeDeliveryResponse(w, eventGUID, deliveryStatusDuplicate)
+
+	case invalid != nil:
+		writeError(w, invalid)
+
+	default:
+		w.WriteHeader(h.unknownEventStatus)
+	}
+}
+
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errInvalidPayload):
+		http.Error(w, "400 Bad Request: "+err.Error(), http.StatusBadRequest)
+
+	case errors.Is(err, errQueueFull):
+		w.Header().Set("Retry-After", retryAfterSeconds)
+		http.Error(w, "503 Service Unavailable: "+err.Error(), http.StatusServiceUnavailable)
+
+	default:
+		http.Error(w, "500 Internal Server Error: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// deliveryResponse is the body responded for an accepted delivery.
+type deliveryResponse struct {
+	DeliveryID string `json:"delivery_id"`
+	Status     string `json:"status"`
+}
+
+func writeDeliveryResponse(w http.ResponseWriter, guid, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+
+	json.NewEncoder(w).Encode(deliveryResponse{DeliveryID: guid, Status: status})
+}
+
+// serveQueueStatus writes the status of event queue as JSON. It is keyed by
+// the names of robots if there are multiple robots.
+func (h *host) serveQueueStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if len(h.robots) == 1 && h.robots[0].name == "" {
+		json.NewEncoder(w).Encode(h.robots[0].queueStatus())
+
+		return
+	}
+
+	v := make(map[string]queueStatus, len(h.robots))
+	for _, d := range h.robots {
+		v[d.name] = d.queueStatus()
+	}
+
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,147 @@
+package framework
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const journalFileSuffix = ".json"
+
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9-]`)
+
+// journal persists the received events until they are handled, so that
+// the events which are not handled before the process exits can be replayed.
+// A nil journal does nothing.
+type journal struct {
+	dir string
+}
+
+// journalEntry is the content of a journal file.
+type journalEntry struct {
+	EventType  string          `json:"event_type"`
+	GUID       string          `json:"guid"`
+	Payload    json.RawMessage `json:"payload"`
+	ReceivedAt time.Time       `json:"received_at"`
+
+	// Handler is the ID of the only handler to run. See delivery.handler.
+	Handler string `json:"handler,omitempty"`
+}
+
+// journalRecord is a journal entry loaded from the file.
+type journalRecord struct {
+	journalEntry
+
+	path string
+}
+
+func newJournal(dir string) (*journal, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &journal{dir: dir}, nil
+}
+
+// save writes the entry to a new file and returns its path. The file name
+// starts with the receipt time, so that the entries can be loaded in order.
+func (j *journal) save(e *journalEntry) (string, error) {
+	if j == nil {
+		return "", nil
+	}
+
+	v, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf(
+		"%020d-%s%s",
+		e.ReceivedAt.UnixNano(),
+		unsafeFileNameChars.ReplaceAllString(e.GUID, "_"),
+		journalFileSuffix,
+	)
+
+	return writeFileAtomically(filepath.Join(j.dir, name), v)
+}
+
+// remove deletes the entry after the event is handled.
+func (j *journal) remove(path string) error {
+	if j == nil || path == "" {
+		return nil
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// load returns all the entries which are not removed, in the order of receipt.
+func (j *journal) load() ([]journalRecord, error) {
+	if j == nil {
+		return nil, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(j.dir, "*"+journalFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+
+	r := make([]journalRecord, 0, len(paths))
+
+	for _, p := range paths {
+		v, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		item := journalRecord{path: p}
+		if err := json.Unmarshal(v, &item.journalEntry); err != nil {
+			return nil, fmt.Errorf("invalid journal file %s: %w", p, err)
+		}
+
+		r = append(r, item)
+	}
+
+	return r, nil
+}
+
+// writeFileAtomically writes data to a temporary file and renames it to path,
+// so that a partially written file will never be seen.
+func writeFileAtomically(path string, data []byte) (string, error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return "", err
+	}
+
+	tmp := f.Name()
+
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+
+		return "", err
+	}
+
+	return path, nil
+}
@@ -0,0 +1,136 @@
+package framework
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"github.com/opensourceways/robot-github-lib/client"
+)
+
+const (
+	metricsNamespace = "robot_github"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// metrics is the prometheus metrics of framework. A nil metrics records nothing.
+type metrics struct {
+	eventsReceived  *prometheus.CounterVec
+	handlerCalls    *prometheus.CounterVec
+	handlerDuration *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+	panics          *prometheus.CounterVec
+	duplicates      *prometheus.CounterVec
+}
+
+// newMetrics registers the metrics of framework and GitHub API calls
+// as well as the go runtime and process collectors to reg.
+func newMetrics(reg prometheus.Registerer) (*metrics, error) {
+	m := &metrics{
+		eventsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "events_received_total",
+			Help:      "Number of webhook events received.",
+		}, []string{"event_type", "action"}),
+
+		handlerCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "handler_calls_total",
+			Help:      "Number of handler calls by the final result, retries included in one call.",
+		}, []string{"event_type", "handler", "result"}),
+
+		handlerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "handler_duration_seconds",
+			Help:      "Duration of handler calls, retries included.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+		}, []string{"event_type", "handler"}),
+
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "in_flight_handlers",
+			Help:      "Number of events accepted and waiting for or running their handlers.",
+		}),
+
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "handler_panics_total",
+			Help:      "Number of panics recovered from the handlers.",
+		}, []string{"robot", "event_type"}),
+
+		duplicates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "duplicate_deliveries_total",
+			Help:      "Number of duplicate deliveries dropped.",
+		}, []string{"robot"}),
+	}
+
+	cs := []prometheus.Collector{
+		m.eventsReceived,
+		m.handlerCalls,
+		m.handlerDuration,
+		m.inFlight,
+		m.panics,
+		m.duplicates,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	}
+	for _, c := range cs {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := client.RegisterMetrics(reg); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *metrics) eventReceived(eventType, action string) {
+	if m != nil {
+		m.eventsReceived.WithLabelValues(eventType, action).Inc()
+	}
+}
+
+func (m *metrics) handlerCalled(eventType, handler string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+
+	m.handlerCalls.WithLabelValues(eventType, handler, result).Inc()
+	m.handlerDuration.WithLabelValues(eventType, handler).Observe(time.Since(start).Seconds())
+}
+
+func (m *metrics) taskAdded() {
+	if m != nil {
+		m.inFlight.Inc()
+	}
+}
+
+func (m *metrics) taskDone() {
+	if m != nil {
+		m.inFlight.Dec()
+	}
+}
+
+func (m *metrics) panicRecovered(robot, eventType string) {
+	if m != nil {
+		m.panics.WithLabelValues(robot, eventType).Inc()
+	}
+}
+
+func (m *metrics) duplicateDropped(robot string) {
+	if m != nil {
+		m.duplicates.WithLabelValues(robot).Inc()
+	}
+}
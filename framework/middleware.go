@@ -0,0 +1,47 @@
+package framework
+
+import (
+	"context"
+
+	"github.com/opensourceways/server-common-lib/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Event describes a webhook event which is being passed to a handler.
+type Event struct {
+	// Type is the event type, which is the value of X-GitHub-Event header.
+	Type string
+
+	// GUID is the delivery ID, which is the value of X-GitHub-Delivery header.
+	GUID string
+
+	// Payload is the parsed event, such as *github.IssuesEvent.
+	// It is *GenericEvent for the generic handlers.
+	Payload interface{}
+
+	// Config is the config of robot which will be passed to the handler.
+	Config config.Config
+
+	// Log is the log entry which will be passed to the handler.
+	Log *logrus.Entry
+
+	// Context is the context of handler. It is cancelled when the process is
+	// interrupted or the handler times out. See ContextOf.
+	Context context.Context
+}
+
+// HandlerFunc is a call of handler which is wrapped by the middlewares.
+type HandlerFunc func(e *Event) error
+
+// Middleware wraps every call of handler. It can do something before and after
+// calling next, or return without calling next to short-circuit the handler.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// chainMiddlewares wraps fn with the middlewares. The first middleware is the outermost one.
+func chainMiddlewares(fn HandlerFunc, middlewares []Middleware) HandlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		fn = middlewares[i](fn)
+	}
+
+	return fn
+}
@@ -0,0 +1,233 @@
+package framework
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/opensourceways/robot-github-lib/client"
+)
+
+// Option configures the framework when running a robot.
+type Option func(*runOptions)
+
+const (
+	defaultWorkers        = 16
+	defaultQueueSize      = 1000
+	defaultHandlerTimeout = 5 * time.Minute
+	defaultDrainDelay     = 5 * time.Second
+	defaultReadyCacheTTL  = 10 * time.Second
+)
+
+type runOptions struct {
+	auth           authenticator
+	hmacSecretFile string
+
+	middlewares []Middleware
+	workers     int
+	queueSize   int
+	journalDir  string
+	journal     *journal
+
+	retry         retryPolicy
+	deadLetterDir string
+	deadLetter    *deadLetterBox
+
+	handlerTimeout time.Duration
+
+	dedupTTL   time.Duration
+	dedupSize  int
+	dedupFile  string
+	deliveries *deliveryCache
+
+	unknownEventStatus int
+
+	drainDelay    time.Duration
+	readyCacheTTL time.Duration
+
+	enableMetrics bool
+	metrics       *metrics
+
+	tracerProvider trace.TracerProvider
+}
+
+func newRunOptions(opts []Option) runOptions {
+	o := runOptions{
+		workers:   defaultWorkers,
+		queueSize: defaultQueueSize,
+		retry: retryPolicy{
+			maxRetries: defaultMaxRetries,
+			delay:      defaultRetryDelay,
+			maxDelay:   defaultMaxRetryWait,
+		},
+		handlerTimeout: defaultHandlerTimeout,
+		dedupTTL:       defaultDedupTTL,
+		dedupSize:      defaultDedupSize,
+
+		unknownEventStatus: http.StatusNoContent,
+		drainDelay:         defaultDrainDelay,
+		readyCacheTTL:      defaultReadyCacheTTL,
+		tracerProvider:     otel.GetTracerProvider(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithMiddleware installs the middlewares which wrap every call of handler.
+// The middlewares are run in the order of installation.
+func WithMiddleware(m ...Middleware) Option {
+	return func(o *runOptions) {
+		o.middlewares = append(o.middlewares, m...)
+	}
+}
+
+// WithWorkers sets the number of workers which handle the events concurrently.
+func WithWorkers(n int) Option {
+	return func(o *runOptions) {
+		if n > 0 {
+			o.workers = n
+		}
+	}
+}
+
+// WithQueueSize sets the max number of events waiting for the workers.
+// The webhook is answered with 503 when the queue is full.
+func WithQueueSize(n int) Option {
+	return func(o *runOptions) {
+		if n > 0 {
+			o.queueSize = n
+		}
+	}
+}
+
+// WithJournal persists the received events to the dir until they are handled.
+// The events which were not handled before the last exit are replayed at startup.
+func WithJournal(dir string) Option {
+	return func(o *runOptions) {
+		o.journalDir = dir
+	}
+}
+
+// WithRetry sets how to retry a handler which returns a retryable error.
+// The delay doubles after each retry and is capped at maxDelay.
+// A jitter is added to the delay.
+func WithRetry(maxRetries int, delay, maxDelay time.Duration) Option {
+	return func(o *runOptions) {
+		o.retry = retryPolicy{
+			maxRetries: maxRetries,
+			delay:      delay,
+			maxDelay:   maxDelay,
+		}
+	}
+}
+
+// WithDeadLetter stores the events, whose handler still returns a retryable
+// error after all the retries, to the dir. See LoadDeadLetters.
+func WithDeadLetter(dir string) Option {
+	return func(o *runOptions) {
+		o.deadLetterDir = dir
+	}
+}
+
+// WithDeliveryDedup sets how long and how many delivery IDs are remembered to
+// drop the duplicate deliveries. A zero ttl or size disables the dropping.
+func WithDeliveryDedup(ttl time.Duration, size int) Option {
+	return func(o *runOptions) {
+		o.dedupTTL = ttl
+		o.dedupSize = size
+	}
+}
+
+// WithDeliveryDedupFile persists the remembered delivery IDs to the file,
+// so that they survive the restart.
+func WithDeliveryDedupFile(path string) Option {
+	return func(o *runOptions) {
+		o.dedupFile = path
+	}
+}
+
+// WithHandlerTimeout sets the timeout of the context passed to each call of handler.
+// A zero timeout means no timeout.
+func WithHandlerTimeout(timeout time.Duration) Option {
+	return func(o *runOptions) {
+		o.handlerTimeout = timeout
+	}
+}
+
+// WithAuth sets how to authenticate the webhooks. The hmacSecretFile is
+// the file of HMAC secret, which is required unless mode is AuthTrustedRelay.
+// The format of secret is the same as the one accepted by client.ValidateWebhook.
+func WithAuth(mode AuthMode, hmacSecretFile string) Option {
+	return func(o *runOptions) {
+		o.auth.mode = mode
+		o.hmacSecretFile = hmacSecretFile
+	}
+}
+
+// WithWebhookValidation customizes the validation of webhooks when the auth mode
+// is not AuthTrustedRelay, for example, client.AllowSHA1Signature().
+func WithWebhookValidation(opts ...client.ValidateOption) Option {
+	return func(o *runOptions) {
+		o.auth.validateOpts = append(o.auth.validateOpts, opts...)
+	}
+}
+
+// WithUnknownEventStatus sets the status code responded for the event type
+// which no handler can handle. It is 204 by default. The code out of the range
+// from 200 to 599 is ignored.
+func WithUnknownEventStatus(code int) Option {
+	return func(o *runOptions) {
+		if code >= 200 && code <= 599 {
+			o.unknownEventStatus = code
+		}
+	}
+}
+
+// WithDrainDelay sets how long to wait between failing the readiness probe and
+// shutting down the server on interrupt, so that the load balancer can notice
+// the draining and stop sending webhooks. It is 5 seconds by default.
+func WithDrainDelay(delay time.Duration) Option {
+	return func(o *runOptions) {
+		if delay >= 0 {
+			o.drainDelay = delay
+		}
+	}
+}
+
+// WithReadyCacheTTL sets how long the result of ReadyChecker is reused by the
+// readiness probe, so that the probes don't call GitHub every time.
+// It is 10 seconds by default. A zero ttl disables the cache.
+func WithReadyCacheTTL(ttl time.Duration) Option {
+	return func(o *runOptions) {
+		if ttl >= 0 {
+			o.readyCacheTTL = ttl
+		}
+	}
+}
+
+// WithMetrics exposes the prometheus metrics of framework and GitHub API calls
+// at /metrics.
+func WithMetrics() Option {
+	return func(o *runOptions) {
+		o.enableMetrics = true
+	}
+}
+
+// WithTracerProvider sets the provider of tracers which trace the receipt and
+// handling of events. It is the global provider of otel by default. The spans
+// of handlers are put in the context of Event and the one returned by ContextOf,
+// so that the GitHub API calls done with that context are traced as their children.
+// The exporter is plugged into tp, for example an in-memory one in tests:
+//
+//	exporter := tracetest.NewInMemoryExporter()
+//	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *runOptions) {
+		o.tracerProvider = tp
+	}
+}
@@ -0,0 +1,298 @@
+package framework
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v36/github"
+	"github.com/opensourceways/server-common-lib/config"
+	"github.com/sirupsen/logrus"
+
+	"github.com/opensourceways/robot-github-lib/client"
+)
+
+const (
+	PermissionAdmin    = "admin"
+	PermissionMaintain = "maintain"
+	PermissionWrite    = "write"
+	PermissionTriage   = "triage"
+	PermissionRead     = "read"
+
+	defaultPermissionCacheTTL = 5 * time.Minute
+	maxPermissionCacheSize    = 10000
+
+	notAllowedComment = "@%s, you are not allowed to %s. It is allowed for %s."
+)
+
+// permissionLevels are the permission levels of repository from low to high.
+var permissionLevels = []string{
+	PermissionRead, PermissionTriage, PermissionWrite, PermissionMaintain, PermissionAdmin,
+}
+
+func permissionRank(level string) int {
+	for i, v := range permissionLevels {
+		if v == level {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Permission is the requirement on who can trigger an action. It is met if any
+// one of the requirements is met. The zero value allows everyone.
+type Permission struct {
+	// Level is the least permission level of repository, such as PermissionWrite.
+	Level string
+
+	// OrgMember allows the members of the org of repository.
+	OrgMember bool
+
+	// Teams are the slugs of teams in the org whose members are allowed.
+	Teams []string
+
+	// Author allows the author of the issue or PR.
+	Author bool
+}
+
+func (p *Permission) isEmpty() bool {
+	return p.Level == "" && !p.OrgMember && len(p.Teams) == 0 && !p.Author
+}
+
+func (p *Permission) validate() error {
+	if p.Level != "" && permissionRank(p.Level) < 0 {
+		return fmt.Errorf("unknown permission level: %s", p.Level)
+	}
+
+	return nil
+}
+
+// String describes who is allowed, such as "users with write permission or
+// higher, or the author".
+func (p Permission) String() string {
+	if p.isEmpty() {
+		return "everyone"
+	}
+
+	var v []string
+
+	if p.Level != "" {
+		s := "users with " + p.Level + " permission"
+		if p.Level != PermissionAdmin {
+			s += " or higher"
+		}
+
+		v = append(v, s)
+	}
+
+	if p.OrgMember {
+		v = append(v, "members of the org")
+	}
+
+	if len(p.Teams) > 0 {
+		v = append(v, "members of team "+strings.Join(p.Teams, ", "))
+	}
+
+	if p.Author {
+		v = append(v, "the author")
+	}
+
+	return strings.Join(v, ", or ")
+}
+
+// PermissionClient is the part of client.Client used to check permissions.
+type PermissionClient interface {
+	GetUserPermissionOfRepo(org, repo, user string) (*github.RepositoryPermissionLevel, error)
+	IsOrgMember(org, login string) (bool, error)
+	IsTeamMember(org, team, login string) (bool, error)
+	CreateIssueComment(is client.PRInfo, comment string) error
+}
+
+type permissionCacheItem struct {
+	value    string
+	expireAt time.Time
+}
+
+// PermissionChecker checks the permissions of users and caches the results.
+type PermissionChecker struct {
+	cli PermissionClient
+	ttl time.Duration
+
+	mu    sync.Mutex
+	cache map[string]permissionCacheItem
+}
+
+// NewPermissionChecker returns a checker which caches the results for ttl.
+// A default ttl is used if it is not positive.
+func NewPermissionChecker(cli PermissionClient, ttl time.Duration) *PermissionChecker {
+	if ttl <= 0 {
+		ttl = defaultPermissionCacheTTL
+	}
+
+	return &PermissionChecker{
+		cli:   cli,
+		ttl:   ttl,
+		cache: map[string]permissionCacheItem{},
+	}
+}
+
+// Allowed tells whether the user meets the permission p on the repository.
+// The author is the author of issue or PR.
+func (pc *PermissionChecker) Allowed(p Permission, org, repo, login, author string) (bool, error) {
+	if p.isEmpty() || (p.Author && login == author) {
+		return true, nil
+	}
+
+	if p.Level != "" {
+		level, err := pc.cached("level/"+org+"/"+repo+"/"+login, func() (string, error) {
+			v, err := pc.cli.GetUserPermissionOfRepo(org, repo, login)
+			if err != nil {
+				return "", err
+			}
+
+			return permissionLevelOf(v), nil
+		})
+		if err != nil {
+			return false, err
+		}
+
+		if permissionRank(level) >= permissionRank(p.Level) {
+			return true, nil
+		}
+	}
+
+	if p.OrgMember {
+		if b, err := pc.cachedBool("org/"+org+"/"+login, func() (bool, error) {
+			return pc.cli.IsOrgMember(org, login)
+		}); err != nil || b {
+			return b, err
+		}
+	}
+
+	for _, team := range p.Teams {
+		team := team
+
+		if b, err := pc.cachedBool("team/"+org+"/"+team+"/"+login, func() (bool, error) {
+			return pc.cli.IsTeamMember(org, team, login)
+		}); err != nil || b {
+			return b, err
+		}
+	}
+
+	return false, nil
+}
+
+// Check tells whether the commenter meets the permission p. If not, it replies
+// that the commenter is not allowed to do action, such as "use /merge".
+func (pc *PermissionChecker) Check(p Permission, e *github.IssueCommentEvent, action string) (bool, error) {
+	org, repo := e.GetRepo().GetOwner().GetLogin(), e.GetRepo().GetName()
+	login := e.GetComment().GetUser().GetLogin()
+
+	b, err := pc.Allowed(p, org, repo, login, e.GetIssue().GetUser().GetLogin())
+	if err != nil || b {
+		return b, err
+	}
+
+	return false, pc.cli.CreateIssueComment(
+		client.PRInfo{Org: org, Repo: repo, Number: e.GetIssue().GetNumber()},
+		fmt.Sprintf(notAllowedComment, login, action, p),
+	)
+}
+
+// Wrap returns a handler which calls h only if the commenter meets the permission p.
+// The denial is replied only for the created comment, the edited or deleted one
+// is dropped silently.
+func (pc *PermissionChecker) Wrap(p Permission, h IssueCommentHandler) IssueCommentHandler {
+	return func(e *github.IssueCommentEvent, cfg config.Config, log *logrus.Entry) error {
+		var (
+			b   bool
+			err error
+		)
+
+		if client.IsCommentCreated(e) {
+			b, err = pc.Check(p, e, "do this")
+		} else {
+			b, err = pc.Allowed(
+				p, e.GetRepo().GetOwner().GetLogin(), e.GetRepo().GetName(),
+				e.GetComment().GetUser().GetLogin(), e.GetIssue().GetUser().GetLogin(),
+			)
+		}
+
+		if err != nil || !b {
+			return err
+		}
+
+		return h(e, cfg, log)
+	}
+}
+
+func (pc *PermissionChecker) cachedBool(key string, get func() (bool, error)) (bool, error) {
+	v, err := pc.cached(key, func() (string, error) {
+		b, err := get()
+
+		return fmt.Sprint(b), err
+	})
+
+	return v == "true", err
+}
+
+// cached returns the value of key from the cache, or gets and caches it if
+// it is missing or expired. The errors are not cached.
+func (pc *PermissionChecker) cached(key string, get func() (string, error)) (string, error) {
+	now := time.Now()
+
+	pc.mu.Lock()
+	item, ok := pc.cache[key]
+	pc.mu.Unlock()
+
+	if ok && now.Before(item.expireAt) {
+		return item.value, nil
+	}
+
+	v, err := get()
+	if err != nil {
+		return "", err
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if len(pc.cache) >= maxPermissionCacheSize {
+		for k, item := range pc.cache {
+			if !now.Before(item.expireAt) {
+				delete(pc.cache, k)
+			}
+		}
+
+		if len(pc.cache) >= maxPermissionCacheSize {
+			pc.cache = map[string]permissionCacheItem{}
+		}
+	}
+
+	pc.cache[key] = permissionCacheItem{value: v, expireAt: now.Add(pc.ttl)}
+
+	return v, nil
+}
+
+// permissionLevelOf returns the permission level of user. The permissions of
+// user are preferred, because the permission field has no triage or maintain.
+func permissionLevelOf(v *github.RepositoryPermissionLevel) string {
+	ps := v.GetUser().GetPermissions()
+
+	switch {
+	case ps["admin"]:
+		return PermissionAdmin
+	case ps["maintain"]:
+		return PermissionMaintain
+	case ps["push"]:
+		return PermissionWrite
+	case ps["triage"]:
+		return PermissionTriage
+	case ps["pull"]:
+		return PermissionRead
+	}
+
+	return v.GetPermission()
+}
@@ -0,0 +1,149 @@
+package framework
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v36/github"
+	"github.com/opensourceways/server-common-lib/config"
+	"github.com/sirupsen/logrus"
+
+	"github.com/opensourceways/robot-github-lib/client"
+)
+
+type fakePermissionClient struct {
+	levels   map[string]string
+	calls    int
+	comments []string
+}
+
+func (c *fakePermissionClient) GetUserPermissionOfRepo(org, repo, user string) (*github.RepositoryPermissionLevel, error) {
+	c.calls++
+
+	return &github.RepositoryPermissionLevel{Permission: github.String(c.levels[user])}, nil
+}
+
+func (c *fakePermissionClient) IsOrgMember(org, login string) (bool, error) {
+	c.calls++
+
+	return false, nil
+}
+
+func (c *fakePermissionClient) IsTeamMember(org, team, login string) (bool, error) {
+	c.calls++
+
+	return false, nil
+}
+
+func (c *fakePermissionClient) CreateIssueComment(is client.PRInfo, comment string) error {
+	c.comments = append(c.comments, comment)
+
+	return nil
+}
+
+func commentEvent(action, login string) *github.IssueCommentEvent {
+	return &github.IssueCommentEvent{
+		Action: github.String(action),
+		Repo: &github.Repository{
+			Name:  github.String("repo"),
+			Owner: &github.User{Login: github.String("owner")},
+		},
+		Issue: &github.Issue{
+			Number: github.Int(1),
+			User:   &github.User{Login: github.String("author")},
+		},
+		Comment: &github.IssueComment{User: &github.User{Login: github.String(login)}},
+	}
+}
+
+func TestPermissionCheckerWrap(t *testing.T) {
+	cases := []struct {
+		name     string
+		action   string
+		login    string
+		called   bool
+		comments int
+	}{
+		{
+			name:   "allowed",
+			action: client.ActionCreated,
+			login:  "writer",
+			called: true,
+		},
+		{
+			name:     "denied",
+			action:   client.ActionCreated,
+			login:    "reader",
+			comments: 1,
+		},
+		{
+			name:   "edited by allowed user",
+			action: "edited",
+			login:  "writer",
+			called: true,
+		},
+		{
+			name:   "edited by denied user",
+			action: "edited",
+			login:  "reader",
+		},
+		{
+			name:   "deleted by denied user",
+			action: "deleted",
+			login:  "reader",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cli := &fakePermissionClient{levels: map[string]string{
+				"writer": PermissionWrite,
+				"reader": PermissionRead,
+			}}
+			pc := NewPermissionChecker(cli, 0)
+
+			called := false
+			h := pc.Wrap(Permission{Level: PermissionWrite}, func(*github.IssueCommentEvent, config.Config, *logrus.Entry) error {
+				called = true
+
+				return nil
+			})
+
+			if err := h(commentEvent(c.action, c.login), nil, logrus.NewEntry(logrus.New())); err != nil {
+				t.Fatalf("handler returns error: %v", err)
+			}
+
+			if called != c.called {
+				t.Errorf("handler called = %v, want %v", called, c.called)
+			}
+
+			if len(cli.comments) != c.comments {
+				t.Errorf("replied %d comments, want %d", len(cli.comments), c.comments)
+			}
+		})
+	}
+}
+
+func TestPermissionCheckerCache(t *testing.T) {
+	cli := &fakePermissionClient{levels: map[string]string{"writer": PermissionWrite}}
+	pc := NewPermissionChecker(cli, 0)
+	p := Permission{Level: PermissionWrite}
+
+	for i := 0; i < 3; i++ {
+		b, err := pc.Allowed(p, "owner", "repo", "writer", "author")
+		if err != nil || !b {
+			t.Fatalf("Allowed() = %v, %v, want true", b, err)
+		}
+	}
+
+	if cli.calls != 1 {
+		t.Errorf("client is called %d times, want 1", cli.calls)
+	}
+
+	if b, _ := pc.Allowed(Permission{Level: PermissionWrite, Author: true}, "owner", "repo", "author", "author"); !b {
+		t.Error("the author is not allowed")
+	}
+
+	if cli.calls != 1 {
+		t.Errorf("client is called for the author, %d calls", cli.calls)
+	}
+}
@@ -0,0 +1,78 @@
+package framework
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultMaxRetries   = 3
+	defaultRetryDelay   = time.Second
+	defaultMaxRetryWait = time.Minute
+)
+
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e retryableError) Unwrap() error {
+	return e.err
+}
+
+// NewRetryableError marks err as retryable. The framework will call the handler
+// again with backoff when it returns a retryable error, such as a temporary
+// failure of GitHub API.
+func NewRetryableError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return retryableError{err: err}
+}
+
+// NewPermanentError returns err as is. It is used to state explicitly that the
+// error will not be retried, which is the default for any error not made by
+// NewRetryableError.
+func NewPermanentError(err error) error {
+	return err
+}
+
+// IsRetryableError tells whether err or any error it wraps is made by NewRetryableError.
+func IsRetryableError(err error) bool {
+	var v retryableError
+
+	return errors.As(err, &v)
+}
+
+// retryPolicy decides how to retry a handler which returns a retryable error.
+type retryPolicy struct {
+	maxRetries int
+	delay      time.Duration
+	maxDelay   time.Duration
+}
+
+// backoff returns the jittered delay before the n-th retry, which starts from 1.
+// The delay doubles each time and is capped at maxDelay.
+func (p *retryPolicy) backoff(n int) time.Duration {
+	d := p.delay
+	for i := 1; i < n && d < p.maxDelay; i++ {
+		d *= 2
+	}
+
+	if d > p.maxDelay {
+		d = p.maxDelay
+	}
+
+	if d <= 1 {
+		return d
+	}
+
+	half := d / 2
+
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
@@ -1,15 +1,25 @@
 package framework
 
 import (
+	"fmt"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"time"
 
 	"github.com/opensourceways/server-common-lib/config"
 	"github.com/opensourceways/server-common-lib/interrupts"
 	"github.com/opensourceways/server-common-lib/options"
+	"github.com/opensourceways/server-common-lib/secret"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
+// HandlerRegister registers the handlers of a robot. Each Register method can be
+// called multiple times and all the handlers registered for an event will be run
+// in the order of registration.
 type HandlerRegister interface {
 	RegisterIssueHandler(IssueHandler)
 	RegisterPullRequestHandler(PullRequestHandler)
@@ -19,6 +29,16 @@ type HandlerRegister interface {
 	RegisterReviewEventHandler(ReviewEventHandler)
 	RegisterReviewCommentEventHandler(ReviewCommentEventHandler)
 	RegisterCommitCommentEventHandler(CommitCommentEventHandler)
+	RegisterCheckRunEventHandler(CheckRunEventHandler)
+	RegisterCheckSuiteEventHandler(CheckSuiteEventHandler)
+	RegisterWorkflowRunEventHandler(WorkflowRunEventHandler)
+	RegisterCreateEventHandler(CreateEventHandler)
+	RegisterDeleteEventHandler(DeleteEventHandler)
+	RegisterForkEventHandler(ForkEventHandler)
+	RegisterRepositoryEventHandler(RepositoryEventHandler)
+	RegisterMemberEventHandler(MemberEventHandler)
+	RegisterLabelEventHandler(LabelEventHandler)
+	RegisterGenericHandler([]string, GenericHandler)
 }
 
 type Robot interface {
@@ -26,30 +46,299 @@ type Robot interface {
 	RegisterEventHandler(HandlerRegister)
 }
 
-func Run(bot Robot, o options.ServiceOptions) {
-	agent := config.NewConfigAgent(bot.NewConfig)
-	if err := agent.Start(o.ConfigFile); err != nil {
-		logrus.WithError(err).Errorf("start config:%s", o.ConfigFile)
+// HostedRobot is a robot run by RunRobots.
+type HostedRobot struct {
+	// Name is the unique name of robot, which is used in logs and metrics.
+	Name string
+
+	Robot Robot
+
+	// ConfigFile is the path of config of the robot.
+	ConfigFile string
+
+	// Options customize the framework for the robot, which are applied after the
+	// ones passed to RunRobots. The files and dirs, such as the one of WithJournal,
+	// must be set here, because they can't be shared by the robots.
+	Options []Option
+}
+
+// Run runs the robot as a service. The opts customize the framework, such as
+// installing middlewares. The liveness and readiness are probed at /healthz and
+// /readyz, and the bot can implement ReadyChecker to take part in the readiness.
+func Run(bot Robot, o options.ServiceOptions, opts ...Option) {
+	runRobots([]HostedRobot{{Robot: bot, ConfigFile: o.ConfigFile}}, o, opts)
+}
+
+// RunRobots runs the robots in one service like Run. Each robot has its own
+// config and queue of events, so that a slow or failing robot will not block
+// the others. They share the HTTP server and each webhook is parsed once for
+// all of them. The options of authentication, metrics, tracing and responses
+// are taken from opts only.
+//
+// The ReadyChecker of each robot doesn't fail /readyz, so that the other robots
+// still receive the webhooks. Its result is listed in the response of /readyz
+// and is probed by /readyz?robot=name.
+//
+// The robots are passed the same event, such as *github.IssuesEvent, and handle
+// it concurrently, so the handlers must not modify the event. Copy it first if
+// a modified one is needed.
+func RunRobots(robots []HostedRobot, o options.ServiceOptions, opts ...Option) {
+	names := sets.NewString()
+	for i := range robots {
+		name := robots[i].Name
+		if name == "" || names.Has(name) {
+			logrus.Errorf("missing or duplicate name of robot: %q", name)
+			return
+		}
+
+		names.Insert(name)
+	}
+
+	runRobots(robots, o, opts)
+}
+
+func runRobots(robots []HostedRobot, o options.ServiceOptions, opts []Option) {
+	ro := newRunOptions(opts)
+
+	if ro.auth.mode != AuthTrustedRelay {
+		if ro.hmacSecretFile == "" {
+			logrus.Error("missing hmac secret file")
+			return
+		}
+
+		secretAgent := new(secret.Agent)
+		if err := secretAgent.Start([]string{ro.hmacSecretFile}); err != nil {
+			logrus.WithError(err).Errorf("start hmac secret:%s", ro.hmacSecretFile)
+			return
+		}
+
+		defer secretAgent.Stop()
+
+		ro.auth.hmacToken = secretAgent.GetTokenGenerator(ro.hmacSecretFile)
+	}
+
+	var reg *prometheus.Registry
+	if ro.enableMetrics {
+		reg = prometheus.NewRegistry()
+
+		m, err := newMetrics(reg)
+		if err != nil {
+			logrus.WithError(err).Error("register metrics")
+			return
+		}
+
+		ro.metrics = m
+	}
+
+	h := &host{
+		auth:               ro.auth,
+		tracer:             ro.tracerProvider.Tracer(tracerName),
+		metrics:            ro.metrics,
+		unknownEventStatus: ro.unknownEventStatus,
+	}
+	hc := &health{}
+
+	robotOpts := make([]runOptions, len(robots))
+	for i := range robots {
+		robotOpts[i] = newRunOptions(append(append([]Option{}, opts...), robots[i].Options...))
+		robotOpts[i].metrics = ro.metrics
+	}
+
+	if err := checkRobotFiles(robots, robotOpts); err != nil {
+		logrus.WithError(err).Error("check robots")
 		return
 	}
 
-	h := handlers{}
-	bot.RegisterEventHandler(&h)
+	for i := range robots {
+		item := &robots[i]
+
+		d, err := startRobot(item, &robotOpts[i])
+		if err != nil {
+			logrus.WithError(err).Errorf("start robot:%s", item.Name)
 
-	d := &dispatcher{agent: &agent, h: h}
+			stopRobots(h.robots)
+
+			return
+		}
+
+		h.robots = append(h.robots, d)
+		hc.robots = append(hc.robots, &robotProbe{
+			name:    item.Name,
+			agent:   d.agent,
+			bot:     item.Robot,
+			ttl:     ro.readyCacheTTL,
+			timeout: readyCheckTimeout,
+		})
+
+		if d.deliveries != nil && d.deliveries.file != "" {
+			interrupts.TickLiteral(func() {
+				saveDeliveries(d)
+			}, time.Minute)
+		}
+	}
 
 	defer interrupts.WaitForGracefulShutdown()
 
+	httpServer := &drainingServer{
+		Server:      &http.Server{Addr: ":" + strconv.Itoa(o.Port)},
+		health:      hc,
+		delay:       ro.drainDelay,
+		gracePeriod: o.GracePeriod,
+		stopped:     make(chan struct{}),
+	}
+
 	interrupts.OnInterrupt(func() {
-		agent.Stop()
-		d.Wait()
+		// the events are accepted until the server is shut down.
+		<-httpServer.stopped
+
+		for _, d := range h.robots {
+			d.agent.Stop()
+		}
+
+		// let the queued events drain until the grace period ends,
+		// then cancel the handlers which are still running.
+		deadline := time.Now().Add(o.GracePeriod)
+		for _, d := range h.robots {
+			if !d.WaitTimeout(time.Until(deadline)) {
+				d.robotLog(logrus.NewEntry(logrus.StandardLogger())).Errorf(
+					"cancel the handlers after grace period, %d events are not handled yet", d.QueueDepth(),
+				)
+			}
+
+			d.Stop()
+		}
+
+		for _, d := range h.robots {
+			d.Wait()
+			saveDeliveries(d)
+		}
 	})
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
 
-	http.Handle("/github-hook", d)
+	http.HandleFunc("/healthz", hc.serveHealthz)
+
+	http.HandleFunc("/readyz", hc.serveReadyz)
+
+	http.Handle("/github-hook", h)
 
-	httpServer := &http.Server{Addr: ":" + strconv.Itoa(o.Port)}
+	http.HandleFunc("/debug/queue", h.serveQueueStatus)
+
+	if reg != nil {
+		http.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	}
 
 	interrupts.ListenAndServe(httpServer, o.GracePeriod)
 }
+
+// checkRobotFiles makes sure that the robots don't share the journal dir,
+// dead letter dir or dedup file, which would mix up their events.
+func checkRobotFiles(robots []HostedRobot, opts []runOptions) error {
+	owners := map[string]string{}
+
+	check := func(kind, path, robot string) error {
+		if path == "" {
+			return nil
+		}
+
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+
+		key := kind + ":" + path
+		if owner, ok := owners[key]; ok {
+			return fmt.Errorf("robot %q and %q use the same %s:%s", owner, robot, kind, path)
+		}
+
+		owners[key] = robot
+
+		return nil
+	}
+
+	for i := range opts {
+		o := &opts[i]
+		name := robots[i].Name
+
+		if err := check("journal dir", o.journalDir, name); err != nil {
+			return err
+		}
+
+		if err := check("dead letter dir", o.deadLetterDir, name); err != nil {
+			return err
+		}
+
+		if err := check("dedup file", o.dedupFile, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stopRobots stops the robots which have been started.
+func stopRobots(robots []*dispatcher) {
+	for _, d := range robots {
+		d.agent.Stop()
+		d.Stop()
+	}
+
+	for _, d := range robots {
+		d.Wait()
+	}
+}
+
+// startRobot starts the config agent and the dispatcher of robot, and replays
+// the events left in its journal.
+func startRobot(r *HostedRobot, ro *runOptions) (*dispatcher, error) {
+	agent := config.NewConfigAgent(r.Robot.NewConfig)
+	if err := agent.Start(r.ConfigFile); err != nil {
+		return nil, fmt.Errorf("start config:%s, %w", r.ConfigFile, err)
+	}
+
+	if ro.journalDir != "" {
+		j, err := newJournal(ro.journalDir)
+		if err != nil {
+			agent.Stop()
+
+			return nil, fmt.Errorf("start journal:%s, %w", ro.journalDir, err)
+		}
+
+		ro.journal = j
+	}
+
+	if ro.deadLetterDir != "" {
+		b, err := newDeadLetterBox(ro.deadLetterDir)
+		if err != nil {
+			agent.Stop()
+
+			return nil, fmt.Errorf("start dead letter box:%s, %w", ro.deadLetterDir, err)
+		}
+
+		ro.deadLetter = b
+	}
+
+	if ro.dedupTTL > 0 && ro.dedupSize > 0 {
+		ro.deliveries = newDeliveryCache(ro.dedupTTL, ro.dedupSize, ro.dedupFile)
+
+		if err := ro.deliveries.load(); err != nil {
+			logrus.WithError(err).Errorf("load delivery records:%s", ro.dedupFile)
+		}
+	}
+
+	h := handlers{}
+	r.Robot.RegisterEventHandler(&h)
+
+	d := newDispatcher(r.Name, &agent, h, ro)
+
+	if err := d.Replay(); err != nil {
+		d.robotLog(logrus.WithError(err)).Errorf("replay journal:%s", ro.journalDir)
+	}
+
+	return d, nil
+}
+
+func saveDeliveries(d *dispatcher) {
+	if err := d.deliveries.save(); err != nil {
+		d.robotLog(logrus.WithError(err)).Errorf("save delivery records:%s", d.deliveries.file)
+	}
+}
@@ -0,0 +1,53 @@
+package framework
+
+import (
+	"encoding/json"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/opensourceways/robot-github-lib/framework"
+
+const (
+	attrEventType = attribute.Key("github.event.type")
+	attrEventGUID = attribute.Key("github.event.delivery")
+	attrAction    = attribute.Key("github.event.action")
+	attrOrg       = attribute.Key("github.org")
+	attrRepo      = attribute.Key("github.repo")
+	attrHandler   = attribute.Key("robot.handler")
+	attrAttempts  = attribute.Key("robot.handler.attempts")
+)
+
+// payloadSummary is the part of payload shared by most of the event types.
+type payloadSummary struct {
+	Action string `json:"action"`
+
+	Repo struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+}
+
+func summarize(payload []byte) (s payloadSummary) {
+	_ = json.Unmarshal(payload, &s)
+
+	return
+}
+
+func (s *payloadSummary) attributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attrAction.String(s.Action),
+		attrOrg.String(s.Repo.Owner.Login),
+		attrRepo.String(s.Repo.Name),
+	}
+}
+
+func eventAttributes(dv delivery) trace.SpanStartEventOption {
+	return trace.WithAttributes(
+		attrEventType.String(dv.eventType),
+		attrEventGUID.String(dv.guid),
+	)
+}